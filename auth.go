@@ -0,0 +1,29 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/softashell/mpd-scrobbler/scrobble"
+)
+
+func authCommand(db scrobble.Database, conf *Config, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: auth <service>")
+	}
+
+	name := args[0]
+	service, ok := conf.Services[name]
+	if !ok {
+		return fmt.Errorf("unknown service %q", name)
+	}
+
+	err := scrobble.Authorize(db, name, service, func(url string) {
+		fmt.Printf("Allow mpd-scrobbler to access your account at\n\n  %s\n\nWaiting for authorization...\n", url)
+	})
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Authorized %s, its password is no longer needed in the config\n", name)
+	return nil
+}
@@ -0,0 +1,38 @@
+package client
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// ParseHost splits host given in the MPD_HOST format understood by mpc into
+// the network and address to dial and an optional password:
+//
+//	localhost            tcp, localhost:port
+//	password@localhost   tcp, localhost:port, password
+//	/run/mpd/socket      unix socket
+//	~/.mpd/socket        unix socket relative to the home directory
+//	@mpd                 abstract unix socket
+func ParseHost(host string, port int) (network, addr, password string) {
+	if i := strings.IndexByte(host, '@'); i > 0 {
+		password, host = host[:i], host[i+1:]
+	}
+
+	switch {
+	case strings.HasPrefix(host, "@"):
+		// abstract sockets are named by a leading @ in net too
+		return "unix", host, password
+	case strings.HasPrefix(host, "~/"):
+		if home, err := os.UserHomeDir(); err == nil {
+			host = filepath.Join(home, host[2:])
+		}
+		return "unix", host, password
+	case strings.HasPrefix(host, "/"):
+		return "unix", host, password
+	}
+
+	return "tcp", net.JoinHostPort(host, strconv.Itoa(port)), password
+}
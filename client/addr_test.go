@@ -0,0 +1,32 @@
+package client
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseHost(t *testing.T) {
+	home, _ := os.UserHomeDir()
+
+	for _, c := range []struct {
+		host                    string
+		network, addr, password string
+	}{
+		{"localhost", "tcp", "localhost:6600", ""},
+		{"secret@localhost", "tcp", "localhost:6600", "secret"},
+		{"::1", "tcp", "[::1]:6600", ""},
+		{"/run/mpd/socket", "unix", "/run/mpd/socket", ""},
+		{"secret@/run/mpd/socket", "unix", "/run/mpd/socket", "secret"},
+		{"~/.mpd/socket", "unix", filepath.Join(home, ".mpd/socket"), ""},
+		{"@mpd", "unix", "@mpd", ""},
+		{"secret@@mpd", "unix", "@mpd", "secret"},
+	} {
+		network, addr, password := ParseHost(c.host, 6600)
+		assert.Equal(t, c.network, network, c.host)
+		assert.Equal(t, c.addr, addr, c.host)
+		assert.Equal(t, c.password, password, c.host)
+	}
+}
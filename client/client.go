@@ -19,31 +19,46 @@ const (
 	TitleHack         = false
 )
 
+var titleRegexp = regexp.MustCompile("^(.+) - (.+)$")
+
 type Client struct {
+	Name              string // used in logs and on submitted songs
 	client            *mpd.Client
 	lock              sync.Mutex
 	net               string
 	addr              string
 	pass              string
+	partition         string
+	channel           string // for client-to-client commands
 	song              mpd.Song
 	pos               mpd.Pos
 	start             int // playtime when current track started
 	playtime          int // last playtime
 	starttime         time.Time
 	submitted         bool
+	resumed           bool       // restored from store but not announced yet
+	store             StateStore // keeps the fields above across restarts
+	saved             *state     // last saved to store
 	quit              chan struct{}
+	done              chan struct{} // closed once Watch returns
+	configLock        sync.RWMutex  // guards the settings below once watching
 	SubmitTime        int
 	SubmitPercentage  int
 	SubmitMinDuration int
 	TitleHack         bool
+	streams           []StreamRule
+	sticker           string // marks loved songs, none if empty
 }
 
-func newClient(net, addr, pass string) (c *mpd.Client, e error) {
+func newClient(net, addr, pass, partition string) (c *mpd.Client, e error) {
 	if pass == "" {
 		c, e = mpd.Dial(net, addr)
 	} else {
 		c, e = mpd.DialAuthenticated(net, addr, pass)
 	}
+	if e == nil && partition != "" {
+		e = c.Partition(partition)
+	}
 	if e != nil && c != nil {
 		c.Close()
 		c = nil
@@ -51,23 +66,30 @@ func newClient(net, addr, pass string) (c *mpd.Client, e error) {
 	return
 }
 
-func Dial(net, addr, pass string) (*Client, error) {
-	c, err := newClient(net, addr, pass)
+// New returns a client for the MPD server at addr, following the named
+// partition or the default one if partition is empty. It doesn't fail if the
+// server can't be reached, but keeps trying to connect in the background.
+func New(name, net, addr, pass, partition string) *Client {
+	c, err := newClient(net, addr, pass, partition)
 	if err != nil {
-		return nil, err
+		log.Printf("[%s] connection failed: %v\n", name, err)
+		c = &mpd.Client{Closed: true}
 	}
 
 	client := &Client{
+		Name:              name,
 		client:            c,
 		net:               net,
 		addr:              addr,
 		pass:              pass,
+		partition:         partition,
 		song:              mpd.Song{},
 		pos:               mpd.Pos{},
 		start:             0,
 		starttime:         time.Now(),
 		submitted:         false,
 		quit:              make(chan struct{}),
+		done:              make(chan struct{}),
 		SubmitTime:        SubmitTime,
 		SubmitPercentage:  SubmitPercentage,
 		SubmitMinDuration: SubmitMinDuration,
@@ -75,19 +97,27 @@ func Dial(net, addr, pass string) (*Client, error) {
 	}
 
 	go client.keepalive()
-	return client, nil
+	return client
 }
 
 func (c *Client) keepalive() {
 	var err error
+
+	// status is only queried on events now, so ping to keep the connection
+	// from hitting MPD's connection_timeout
+	ping := time.NewTicker(30 * time.Second)
+	defer ping.Stop()
+
 	for {
 		select {
-		case <-time.After(30 * time.Second):
+		case <-ping.C:
 			c.lock.Lock()
 
-			err = c.client.Ping()
-			if err != nil {
-				log.Println("ping failed:", err)
+			if !c.client.Closed {
+				err = c.client.Ping()
+				if err != nil {
+					log.Printf("[%s] ping failed: %v\n", c.Name, err)
+				}
 			}
 
 			c.lock.Unlock()
@@ -98,11 +128,11 @@ func (c *Client) keepalive() {
 			c.lock.Unlock()
 
 			if closed {
-				log.Println("detected closed socket, reconnecting")
+				log.Printf("[%s] detected closed socket, reconnecting\n", c.Name)
 
-				cc, err := newClient(c.net, c.addr, c.pass)
+				cc, err := newClient(c.net, c.addr, c.pass, c.partition)
 				if err != nil {
-					log.Println("reconnection fail:", err)
+					log.Printf("[%s] reconnection fail: %v\n", c.Name, err)
 					time.Sleep(5 * time.Second)
 				} else {
 					c.lock.Lock()
@@ -110,7 +140,7 @@ func (c *Client) keepalive() {
 					c.client.Close()
 					c.client = cc
 
-					log.Println("successfully reconnected")
+					log.Printf("[%s] successfully reconnected\n", c.Name)
 
 					c.lock.Unlock()
 				}
@@ -122,6 +152,17 @@ func (c *Client) keepalive() {
 	}
 }
 
+// Partitions returns the names of the partitions of the MPD server at addr.
+func Partitions(net, addr, pass string) ([]string, error) {
+	c, err := newClient(net, addr, pass, "")
+	if err != nil {
+		return nil, err
+	}
+	defer c.Close()
+
+	return c.ListPartitions()
+}
+
 func songsEqual(a, b mpd.Song) bool {
 	return a.File == b.File &&
 		a.Title == b.Title &&
@@ -130,48 +171,61 @@ func songsEqual(a, b mpd.Song) bool {
 		a.AlbumArtist == b.AlbumArtist
 }
 
+// Close stops watching. It gives Watch a moment to save the state, which it
+// can't while it waits for a song to be taken by the caller.
 func (c *Client) Close() error {
 	close(c.quit)
+	select {
+	case <-c.done:
+	case <-time.After(time.Second):
+	}
 	return c.client.Close()
 }
 
 func (c *Client) Song() Song {
-	var (
-		err       error
-		tracknumu uint64
-		tracknum  int32
-		durationf float64
-	)
-
-	trnum := c.song.Track
-	// handle `num/num` format
-	if i := strings.IndexByte(c.song.Track, '/'); i >= 0 {
-		trnum = c.song.Track[:i]
-	}
-	if trnum != "" {
-		tracknumu, err = strconv.ParseUint(trnum, 10, 32)
-		tracknum = int32(tracknumu)
-		if err != nil || tracknum < 0 {
-			tracknum = -1
-		}
-	} else {
-		tracknum = -1
-	}
-	durationf, err = strconv.ParseFloat(c.song.Duration, 64)
+	durationf, err := strconv.ParseFloat(c.song.Duration, 64)
 	if err != nil || durationf < 0.0 {
 		durationf = 0.0
 	}
 	return Song{
-		Title:       c.song.Title,
-		Album:       c.song.Album,
-		Artist:      c.song.Artist,
-		AlbumArtist: c.song.AlbumArtist,
-		TrackNumber: tracknum,
-		Duration:    uint32(durationf + 0.5),
-		Start:       c.starttime,
+		Title:          c.song.Title,
+		Album:          c.song.Album,
+		Artist:         c.song.Artist,
+		AlbumArtist:    c.song.AlbumArtist,
+		TrackNumber:    parseNumber(c.song.Track),
+		DiscNumber:     parseNumber(c.song.Disc),
+		Date:           c.song.Date,
+		Duration:       uint32(durationf + 0.5),
+		Start:          c.starttime,
+		Instance:       c.Name,
+		Artists:        c.song.Artists,
+		AlbumArtists:   c.song.AlbumArtists,
+		Genres:         c.song.Genres,
+		RecordingID:    c.song.RecordingID,
+		TrackID:        c.song.TrackID,
+		ReleaseID:      c.song.ReleaseID,
+		ReleaseGroupID: c.song.ReleaseGroupID,
+		ArtistIDs:      c.song.ArtistIDs,
 	}
 }
 
+// parseNumber parses track and disc numbers, which may be given as
+// `num/total`. It returns -1 if there is no valid number.
+func parseNumber(s string) int32 {
+	// handle `num/num` format
+	if i := strings.IndexByte(s, '/'); i >= 0 {
+		s = s[:i]
+	}
+	if s == "" {
+		return -1
+	}
+	n, err := strconv.ParseUint(s, 10, 31)
+	if err != nil {
+		return -1
+	}
+	return int32(n)
+}
+
 func (c *Client) flushCurrent(toSubmit chan<- Song) {
 	if c.canSubmit() {
 		c.submitted = true
@@ -179,125 +233,292 @@ func (c *Client) flushCurrent(toSubmit chan<- Song) {
 	}
 }
 
-func (c *Client) Watch(interval time.Duration, toSubmit chan<- Song, nowPlaying chan<- Song) {
-	var song mpd.Song
-	var pos mpd.Pos
-	var playtime int
-	var playing bool
-	var err error
+// Subscribe makes Watch accept "love" and "unlove" commands for the current
+// song sent to channel by other clients, e.g. with `mpc sendmessage`. It
+// has to be called before Watch.
+func (c *Client) Subscribe(channel string) {
+	c.channel = channel
+}
+
+// Watch follows MPD's player and playlist idle events and sends songs that
+// should be scrobbled to toSubmit and newly started songs to nowPlaying.
+// interval controls how often elapsed time is refreshed between events.
+// Commands received on the subscribed channel are sent to loves.
+func (c *Client) Watch(interval time.Duration, toSubmit chan<- Song, nowPlaying chan<- Song, loves chan<- Love) {
+	defer close(c.done)
+
+	w := c.watcher()
+	if w == nil {
+		return
+	}
+	defer closeWatcher(w)
 
-	r := regexp.MustCompile("^(.+) - (.+)$")
+	refresh := time.NewTicker(interval)
+	defer refresh.Stop()
 
-	for _ = range time.Tick(interval) {
-		// quit signal check
+	// pick up whatever is playing right now, continuing where a previous
+	// run left off
+	c.restoreState()
+	c.update(toSubmit, nowPlaying)
+	c.saveState(false)
+
+	for {
 		select {
 		case <-c.quit:
+			c.saveState(true)
 			return
-		default:
+
+		case <-w.Event:
+
+		case msg := <-w.Message:
+			c.handleMessage(msg, loves)
+			continue
+
+		case err := <-w.Error:
+			log.Printf("[%s] err(Watcher): %v\n", c.Name, err)
+			closeWatcher(w)
+			if w = c.watcher(); w == nil {
+				c.saveState(true)
+				return
+			}
+
+		case <-refresh.C:
 		}
 
-		c.lock.Lock()
+		c.update(toSubmit, nowPlaying)
+		c.saveState(false)
+	}
+}
 
-		pos, playing, err = c.client.CurrentPos()
-		if !playing {
-			goto nocurrent
+// watcher connects an idle watcher for player and playlist events, retrying
+// until it succeeds or the client is closed.
+func (c *Client) watcher() *mpd.Watcher {
+	subsystems := []string{"player", "playlist"}
+	var channels []string
+	if c.channel != "" {
+		subsystems = append(subsystems, "message")
+		channels = []string{c.channel}
+	}
+
+	for {
+		w, err := mpd.NewPartitionWatcher(c.net, c.addr, c.pass, c.partition, channels, subsystems...)
+		if err == nil {
+			return w
 		}
-		if err != nil {
-			log.Println("err(CurrentPos):", err)
-			goto nocurrent
+		log.Printf("[%s] err(NewWatcher): %v\n", c.Name, err)
+
+		select {
+		case <-c.quit:
+			return nil
+		case <-time.After(5 * time.Second):
 		}
+	}
+}
 
-		playtime, err = c.client.PlayTime()
-		if err != nil {
-			log.Println("err(PlayTime):", err)
-			goto nocurrent
+func closeWatcher(w *mpd.Watcher) {
+	// keep draining so the idle loop can't block on a send while closing
+	go func() {
+		for range w.Event {
+		}
+	}()
+	go func() {
+		for range w.Message {
 		}
+	}()
+	go func() {
+		for range w.Error {
+		}
+	}()
+	w.Close()
+}
+
+// handleMessage runs a command sent to the subscribed channel.
+func (c *Client) handleMessage(msg mpd.Message, loves chan<- Love) {
+	var love bool
+	cmd := strings.TrimSpace(msg.Message)
+	switch cmd {
+	case "love":
+		love = true
+	case "unlove":
+	default:
+		log.Printf("[%s] unknown command on %s: %q\n", c.Name, msg.Channel, msg.Message)
+		return
+	}
+
+	if c.song.Title == "" || c.song.Artist == "" {
+		log.Printf("[%s] nothing to %s\n", c.Name, cmd)
+		return
+	}
+	loves <- Love{c.Song(), love}
+	c.setLoved(c.song.File, love)
+}
+
+// Configure changes the submission settings. Unlike setting the fields
+// directly it is safe while Watch is running and keeps the current song.
+func (c *Client) Configure(submitTime, submitPercentage, submitMinDuration int, titleHack bool) {
+	c.configLock.Lock()
+	defer c.configLock.Unlock()
+
+	c.SubmitTime = submitTime
+	c.SubmitPercentage = submitPercentage
+	c.SubmitMinDuration = submitMinDuration
+	c.TitleHack = titleHack
+}
+
+// ConfigureStreams sets the rules for reading stream titles, the first rule
+// matching a station is used. Like Configure it is safe while watching.
+func (c *Client) ConfigureStreams(rules []StreamRule) {
+	c.configLock.Lock()
+	defer c.configLock.Unlock()
 
-		song, err = c.client.CurrentSong()
-		if err != nil {
-			log.Println("err(CurrentSong):", err)
+	c.streams = rules
+}
+
+func (c *Client) update(toSubmit chan<- Song, nowPlaying chan<- Song) {
+	var song mpd.Song
+	var pos mpd.Pos
+	var playtime int
+	var playing, ok, ignored bool
+	var err error
+
+	// the settings are copied rather than locked for the whole update, as
+	// sending songs blocks until the main loop, which also reloads the
+	// config, takes them
+	c.configLock.RLock()
+	titleHack := c.TitleHack
+	c.configLock.RUnlock()
+
+	c.lock.Lock()
+
+	if c.client.Closed {
+		// keepalive is reconnecting
+		goto nocurrent
+	}
+
+	playtime, err = c.client.PlayTime()
+	if err != nil {
+		log.Printf("[%s] err(PlayTime): %v\n", c.Name, err)
+		goto nocurrent
+	}
+
+	if playtime < c.playtime {
+		// server was prolly restarted. it normally cannot go back in time.
+		// shift start playtime
+		c.start -= c.playtime - playtime
+	}
+	// events arrive as soon as the song changes, so this is also the
+	// final playtime of the previous song
+	c.playtime = playtime
+
+	pos, playing, err = c.client.CurrentPos()
+	if !playing {
+		goto nocurrent
+	}
+	if err != nil {
+		log.Printf("[%s] err(CurrentPos): %v\n", c.Name, err)
+		goto nocurrent
+	}
+
+	song, err = c.client.CurrentSong()
+	if err != nil {
+		log.Printf("[%s] err(CurrentSong): %v\n", c.Name, err)
+		goto nocurrent
+	}
+
+	if isStream(song.File) {
+		if song, ok = c.streamSong(song); !ok {
+			ignored = true
 			goto nocurrent
 		}
+	}
 
-		c.lock.Unlock()
+	c.lock.Unlock()
 
-		if song.Artist == "" && song.AlbumArtist != "" {
-			song.Artist = song.AlbumArtist
-		}
-		if song.Artist == "" && song.Title != "" && c.TitleHack {
-			matches := r.FindStringSubmatch(song.Title)
-			if matches != nil {
-				song.Artist = matches[1]
-				song.Title = matches[2]
-			}
+	if song.Artist == "" && song.AlbumArtist != "" {
+		song.Artist = song.AlbumArtist
+		song.Artists = song.AlbumArtists
+	}
+	if song.Artist == "" && song.Title != "" && titleHack {
+		matches := titleRegexp.FindStringSubmatch(song.Title)
+		if matches != nil {
+			song.Artist = matches[1]
+			song.Title = matches[2]
 		}
+	}
 
-		// new song
-		if !songsEqual(song, c.song) {
-			c.flushCurrent(toSubmit)
-
-			c.song = song
-			c.pos = pos
-			c.start = playtime
-			c.starttime = time.Now().UTC()
-
-			c.submitted = false
-			nowPlaying <- c.Song()
-		} else if playtime < c.playtime {
-			// server was prolly restarted. it normally cannot go back in time.
-			// shift start playtime
-			c.start -= c.playtime - playtime
-		}
+	// new song
+	if !songsEqual(song, c.song) {
+		c.flushCurrent(toSubmit)
+
+		c.song = song
+		c.pos = pos
+		c.start = playtime
+		c.starttime = time.Now().UTC()
 
-		c.playtime = playtime
-
-		// more progress
-		if pos != c.pos {
-			if pos.Seconds < c.pos.Seconds {
-				// new position is smaller. user seeked back or repeated track
-				if c.submitted || c.canSubmit() {
-					if !c.submitted {
-						toSubmit <- c.Song()
-					}
-					// allow to relisten, if it's already submitted
-					c.submitted = false
-					c.starttime = time.Now().UTC()
-					// reset start position, so that relisten will be calculated properly
+		c.submitted = false
+		nowPlaying <- c.Song()
+	} else if c.resumed {
+		// the services don't know about it after a restart
+		nowPlaying <- c.Song()
+	}
+	c.resumed = false
+
+	// more progress
+	if pos != c.pos {
+		if pos.Seconds < c.pos.Seconds {
+			// new position is smaller. user seeked back or repeated track
+			if c.submitted || c.canSubmit() {
+				if !c.submitted {
+					toSubmit <- c.Song()
+				}
+				// allow to relisten, if it's already submitted
+				c.submitted = false
+				c.starttime = time.Now().UTC()
+				// reset start position, so that relisten will be calculated properly
+				c.start = playtime
+				// incase of relistens...
+				nowPlaying <- c.Song()
+			} else {
+				// not yet submitted, so increase c.start by ammount of time jumped to past
+				c.start += c.pos.Seconds - pos.Seconds
+				// but don't make it worse than fresh listen
+				if c.start > playtime {
 					c.start = playtime
-					// incase of relistens...
-					nowPlaying <- c.Song()
-				} else {
-					// not yet submitted, so increase c.start by ammount of time jumped to past
-					c.start += c.pos.Seconds - pos.Seconds
-					// but don't make it worse than fresh listen
-					if c.start > playtime {
-						c.start = playtime
-					}
 				}
 			}
-			c.pos = pos
 		}
+		c.pos = pos
+	}
 
-		continue
+	return
 
-	nocurrent:
-		c.lock.Unlock()
+nocurrent:
+	c.lock.Unlock()
 
-		c.flushCurrent(toSubmit)
+	c.flushCurrent(toSubmit)
+	if ignored {
+		// time spent on a jingle doesn't count for the song before it
+		c.song = mpd.Song{}
 	}
 }
 
 func (c *Client) canSubmit() bool {
+	c.configLock.RLock()
+	submitTime := c.SubmitTime
+	submitPercentage := c.SubmitPercentage
+	submitMinDuration := c.SubmitMinDuration
+	c.configLock.RUnlock()
+
 	if c.submitted ||
-		(c.pos.Length > 0 && c.pos.Length < c.SubmitMinDuration) ||
+		(c.pos.Length > 0 && c.pos.Length < submitMinDuration) ||
 		c.song.Title == "" || c.song.Artist == "" {
 		return false
 	}
 
 	if c.pos.Length > 0 {
-		return c.playtime-c.start >= c.SubmitTime ||
-			float64(c.playtime-c.start) >= (float64(c.pos.Length)*float64(c.SubmitPercentage))/100
+		return c.playtime-c.start >= submitTime ||
+			float64(c.playtime-c.start) >= (float64(c.pos.Length)*float64(submitPercentage))/100
 	}
 
-	return c.playtime-c.start >= c.SubmitTime
+	return c.playtime-c.start >= submitTime
 }
@@ -0,0 +1,93 @@
+package client
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/softashell/mpd-scrobbler/client/mpd"
+)
+
+func TestParseNumber(t *testing.T) {
+	for s, n := range map[string]int32{
+		"":      -1,
+		"3":     3,
+		"3/12":  3,
+		"/12":   -1,
+		"x":     -1,
+		"-2":    -1,
+		"01":    1,
+		"99999": 99999,
+	} {
+		assert.Equal(t, n, parseNumber(s), s)
+	}
+}
+
+func TestHandleMessage(t *testing.T) {
+	assert := assert.New(t)
+
+	loves := make(chan Love, 3)
+	c := &Client{Name: "test"}
+
+	c.handleMessage(mpd.Message{Channel: "mpdscribble", Message: "love"}, loves)
+	assert.Len(loves, 0)
+
+	c.song = mpd.Song{Title: "Song", Artist: "John"}
+	c.handleMessage(mpd.Message{Channel: "mpdscribble", Message: "love\n"}, loves)
+	c.handleMessage(mpd.Message{Channel: "mpdscribble", Message: "hate"}, loves)
+	c.handleMessage(mpd.Message{Channel: "mpdscribble", Message: "unlove"}, loves)
+
+	if assert.Len(loves, 2) {
+		l := <-loves
+		assert.True(l.Love)
+		assert.Equal("Song", l.Song.Title)
+		assert.Equal("test", l.Song.Instance)
+		assert.False((<-loves).Love)
+	}
+}
+
+// memStore keeps states in memory.
+type memStore map[string][]byte
+
+func (m memStore) State(name string) ([]byte, error) { return m[name], nil }
+
+func (m memStore) SetState(name string, state []byte) error {
+	m[name] = state
+	return nil
+}
+
+func TestResumeState(t *testing.T) {
+	assert := assert.New(t)
+
+	store := memStore{}
+	c := &Client{Name: "test", store: store}
+	c.song = mpd.Song{Title: "Song", Artist: "John", File: "john/song.flac", Id: "7"}
+	c.pos = mpd.Pos{Seconds: 90, Length: 200}
+	c.start = 10
+	c.playtime = 100
+	c.starttime = time.Date(2024, 3, 1, 21, 14, 0, 0, time.UTC)
+	c.saveState(false)
+	saved := store["test"]
+	assert.NotNil(saved)
+
+	// progress alone is only saved when stopping
+	c.pos.Seconds, c.playtime = 120, 130
+	c.saveState(false)
+	assert.Equal(saved, store["test"])
+	c.saveState(true)
+	assert.NotEqual(saved, store["test"])
+
+	var s state
+	assert.Nil(json.Unmarshal(store["test"], &s))
+
+	other := &Client{Name: "test"}
+	assert.False(other.resume(s, mpd.Song{File: "john/other.flac", Id: "7"}))
+	assert.False(other.resume(s, mpd.Song{File: "john/song.flac", Id: "8"}))
+	assert.Equal(mpd.Song{}, other.song)
+
+	assert.True(other.resume(s, mpd.Song{File: "john/song.flac", Id: "7"}))
+	assert.Equal(c.state(), other.state())
+	assert.True(other.resumed)
+}
@@ -42,6 +42,18 @@ type Client struct {
 // Attrs is a set of attributes returned by MPD.
 type Attrs map[string]string
 
+// MultiAttrs is a set of attributes returned by MPD where keys, like tags,
+// can be repeated.
+type MultiAttrs map[string][]string
+
+// Get returns the first value of key, or an empty string.
+func (a MultiAttrs) Get(key string) string {
+	if v := a[key]; len(v) > 0 {
+		return v[0]
+	}
+	return ""
+}
+
 // Dial connects to MPD listening on address addr (e.g. "127.0.0.1:6600")
 // on network network (e.g. "tcp").
 func Dial(network, addr string) (c *Client, err error) {
@@ -102,6 +114,76 @@ func (c *Client) Close() (err error) {
 	return
 }
 
+// Partition switches the connection to the named partition. Player commands
+// and idle events then apply to that partition only. Requires MPD 0.22.
+func (c *Client) Partition(name string) error {
+	return c.Command("partition %s", name).OK()
+}
+
+// ListPartitions returns the names of all partitions.
+func (c *Client) ListPartitions() ([]string, error) {
+	return c.Command("listpartitions").Strings("partition")
+}
+
+// Message is a message sent to a channel by another client.
+type Message struct {
+	Channel string
+	Message string
+}
+
+// Subscribe subscribes the connection to channel, creating it if needed.
+func (c *Client) Subscribe(channel string) error {
+	return c.Command("subscribe %s", channel).OK()
+}
+
+// ReadMessages returns the messages received on subscribed channels since
+// the last call.
+func (c *Client) ReadMessages() ([]Message, error) {
+	attrs, err := c.Command("readmessages").MultiAttrs()
+	if err != nil {
+		return nil, err
+	}
+
+	channels, messages := attrs["channel"], attrs["message"]
+	if len(channels) != len(messages) {
+		return nil, textproto.ProtocolError("unpaired channel messages")
+	}
+	msgs := make([]Message, len(channels))
+	for i := range channels {
+		msgs[i] = Message{channels[i], messages[i]}
+	}
+	return msgs, nil
+}
+
+// Find returns the files of the songs in the database whose tags exactly
+// match the given tag and value pairs, e.g. Find("artist", "A", "title", "T").
+func (c *Client) Find(pairs ...string) ([]string, error) {
+	args := make([]interface{}, len(pairs))
+	for i, s := range pairs {
+		if i%2 == 0 {
+			args[i] = Quoted(s) // tag name
+		} else {
+			args[i] = s
+		}
+	}
+
+	attrs, err := c.Command("find"+strings.Repeat(" %s", len(args)), args...).MultiAttrs()
+	if err != nil {
+		return nil, err
+	}
+	return attrs["file"], nil
+}
+
+// StickerSet sets the sticker name of the song at uri to value.
+func (c *Client) StickerSet(uri, name, value string) error {
+	return c.Command("sticker set song %s %s %s", uri, name, value).OK()
+}
+
+// StickerDelete removes the sticker name from the song at uri.
+func (c *Client) StickerDelete(uri, name string) error {
+	return c.Command("sticker delete song %s %s", uri, name).OK()
+}
+
 // Ping sends a no-op message to MPD. It's useful for keeping the connection alive.
 func (c *Client) Ping() error {
 	return c.Command("ping").OK()
@@ -146,14 +228,51 @@ func (c *Client) readAttrs(terminator string) (attrs Attrs, err error) {
 	return
 }
 
+func (c *Client) readMultiAttrs(terminator string) (attrs MultiAttrs, err error) {
+	attrs = make(MultiAttrs)
+	for {
+		line, err := c.text.ReadLine()
+		if err != nil {
+			return nil, err
+		}
+		if line == terminator {
+			break
+		}
+		if strings.HasPrefix(line, "ACK ") {
+			return nil, textproto.ProtocolError(line)
+		}
+		z := strings.Index(line, ": ")
+		if z < 0 {
+			return nil, textproto.ProtocolError("can't parse line: " + line)
+		}
+		key := line[0:z]
+		attrs[key] = append(attrs[key], line[z+2:])
+	}
+	return
+}
+
 type Song struct {
-	Title       string
-	Artist      string
-	Album       string
-	AlbumArtist string
-	Track       string // tracknumber
-	File        string // filename
-	Duration    string // in seconds, float pt value
+	Title        string
+	Artist       string // first of Artists
+	Album        string
+	AlbumArtist  string // first of AlbumArtists
+	Track        string // tracknumber
+	Disc         string // discnumber
+	Date         string
+	File         string // filename
+	Id           string // position independent id in the queue
+	Duration     string // in seconds, float pt value
+	Name         string // station name of streams
+	Artists      []string
+	AlbumArtists []string
+	Genres       []string
+
+	// MusicBrainz identifiers
+	RecordingID    string   // MUSICBRAINZ_TRACKID
+	TrackID        string   // MUSICBRAINZ_RELEASETRACKID
+	ReleaseID      string   // MUSICBRAINZ_ALBUMID
+	ReleaseGroupID string   // MUSICBRAINZ_RELEASEGROUPID
+	ArtistIDs      []string // MUSICBRAINZ_ARTISTID
 }
 
 type Pos struct {
@@ -164,19 +283,31 @@ type Pos struct {
 
 // CurrentSong returns information about the current song in the playlist.
 func (c *Client) CurrentSong() (Song, error) {
-	s, err := c.Command("currentsong").Attrs()
+	s, err := c.Command("currentsong").MultiAttrs()
 	if err != nil {
-		return Song{}, nil
+		return Song{}, err
 	}
 
 	return Song{
-		Title:       s["Title"],
-		Artist:      s["Artist"],
-		Album:       s["Album"],
-		AlbumArtist: s["AlbumArtist"],
-		Track:       s["Track"],
-		File:        s["file"],
-		Duration:    s["duration"],
+		Title:          s.Get("Title"),
+		Artist:         s.Get("Artist"),
+		Album:          s.Get("Album"),
+		AlbumArtist:    s.Get("AlbumArtist"),
+		Track:          s.Get("Track"),
+		Disc:           s.Get("Disc"),
+		Date:           s.Get("Date"),
+		File:           s.Get("file"),
+		Id:             s.Get("Id"),
+		Duration:       s.Get("duration"),
+		Name:           s.Get("Name"),
+		Artists:        s["Artist"],
+		AlbumArtists:   s["AlbumArtist"],
+		Genres:         s["Genre"],
+		RecordingID:    s.Get("MUSICBRAINZ_TRACKID"),
+		TrackID:        s.Get("MUSICBRAINZ_RELEASETRACKID"),
+		ReleaseID:      s.Get("MUSICBRAINZ_ALBUMID"),
+		ReleaseGroupID: s.Get("MUSICBRAINZ_RELEASEGROUPID"),
+		ArtistIDs:      s["MUSICBRAINZ_ARTISTID"],
 	}, nil
 }
 
@@ -207,7 +338,10 @@ func (c *Client) CurrentPos() (pos Pos, playing bool, err error) {
 	if err != nil {
 		return
 	}
-	pos.Percent = float64(pos.Seconds) * 100 / float64(pos.Length)
+	// streams have no length
+	if pos.Length > 0 {
+		pos.Percent = float64(pos.Seconds) * 100 / float64(pos.Length)
+	}
 	return
 }
 
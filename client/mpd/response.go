@@ -42,7 +42,7 @@ func (cmd *Command) String() string {
 
 // OK sends command to server and checks for error.
 func (cmd *Command) OK() error {
-	id, err := cmd.client.cmd(cmd.cmd)
+	id, err := cmd.client.cmd("%s", cmd.cmd)
 	if err != nil {
 		return err
 	}
@@ -53,7 +53,7 @@ func (cmd *Command) OK() error {
 
 // Attrs sends command to server and reads attributes returned in response.
 func (cmd *Command) Attrs() (Attrs, error) {
-	id, err := cmd.client.cmd(cmd.cmd)
+	id, err := cmd.client.cmd("%s", cmd.cmd)
 	if err != nil {
 		return nil, err
 	}
@@ -62,10 +62,22 @@ func (cmd *Command) Attrs() (Attrs, error) {
 	return cmd.client.readAttrs("OK")
 }
 
+// MultiAttrs sends command to server and reads attributes returned in
+// response, keeping every value of repeated attributes.
+func (cmd *Command) MultiAttrs() (MultiAttrs, error) {
+	id, err := cmd.client.cmd("%s", cmd.cmd)
+	if err != nil {
+		return nil, err
+	}
+	cmd.client.text.StartResponse(id)
+	defer cmd.client.text.EndResponse(id)
+	return cmd.client.readMultiAttrs("OK")
+}
+
 // Strings sends command to server and reads a list of strings returned in response.
 // Each string have the key key.
 func (cmd *Command) Strings(key string) ([]string, error) {
-	id, err := cmd.client.cmd(cmd.cmd)
+	id, err := cmd.client.cmd("%s", cmd.cmd)
 	if err != nil {
 		return nil, err
 	}
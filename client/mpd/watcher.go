@@ -6,12 +6,13 @@ package mpd
 
 // Watcher represents a MPD client connection that can be watched for events.
 type Watcher struct {
-	conn  *Client       // client connection to MPD
-	exit  chan bool     // channel used to ask loop to terminate
-	done  chan bool     // channel indicating loop has terminated
-	names chan []string // channel to set new subsystems to watch
-	Event chan string   // event channel
-	Error chan error    // error channel
+	conn    *Client       // client connection to MPD
+	exit    chan bool     // channel used to ask loop to terminate
+	done    chan bool     // channel indicating loop has terminated
+	names   chan []string // channel to set new subsystems to watch
+	Event   chan string   // event channel
+	Message chan Message  // messages on subscribed channels
+	Error   chan error    // error channel
 }
 
 // NewWatcher connects to MPD server and watches for changes in subsystems
@@ -20,15 +21,36 @@ type Watcher struct {
 // See http://www.musicpd.org/doc/protocol/command_reference.html#command_idle
 // for valid subsystem names.
 func NewWatcher(net, addr, passwd string, names ...string) (w *Watcher, err error) {
+	return NewPartitionWatcher(net, addr, passwd, "", nil, names...)
+}
+
+// NewPartitionWatcher is like NewWatcher, but watches the named partition
+// instead of the default one. An empty partition means the default one.
+// The watcher subscribes to channels, messages sent to them are read when
+// the "message" subsystem changes and delivered on Message.
+func NewPartitionWatcher(net, addr, passwd, partition string, channels []string, names ...string) (w *Watcher, err error) {
 	conn, err := DialAuthenticated(net, addr, passwd)
 	if err != nil {
 		return
 	}
+	if partition != "" {
+		err = conn.Partition(partition)
+	}
+	for _, channel := range channels {
+		if err == nil {
+			err = conn.Subscribe(channel)
+		}
+	}
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
 	w = &Watcher{
-		conn:  conn,
-		Event: make(chan string),
-		Error: make(chan error),
-		done:  make(chan bool),
+		conn:    conn,
+		Event:   make(chan string),
+		Message: make(chan Message),
+		Error:   make(chan error),
+		done:    make(chan bool),
 		// Buffer channels to avoid race conditions with noIdle
 		names: make(chan []string, 1),
 		exit:  make(chan bool, 1),
@@ -61,6 +83,9 @@ func (w *Watcher) watch(names ...string) {
 			w.Error <- err
 		default:
 			for _, name := range changed {
+				if name == "message" {
+					w.readMessages()
+				}
 				w.Event <- name
 			}
 		}
@@ -78,8 +103,20 @@ func (w *Watcher) watch(names ...string) {
 	}
 }
 
+func (w *Watcher) readMessages() {
+	msgs, err := w.conn.ReadMessages()
+	if err != nil {
+		w.Error <- err
+		return
+	}
+	for _, msg := range msgs {
+		w.Message <- msg
+	}
+}
+
 func (w *Watcher) closeChans() {
 	close(w.Event)
+	close(w.Message)
 	close(w.Error)
 	close(w.names)
 	close(w.exit)
@@ -90,6 +127,7 @@ func (w *Watcher) consume() {
 	for {
 		select {
 		case <-w.Event:
+		case <-w.Message:
 		case <-w.Error:
 		default:
 			return
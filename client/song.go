@@ -3,11 +3,31 @@ package client
 import "time"
 
 type Song struct {
-	Title       string
-	Artist      string
-	Album       string
-	AlbumArtist string
-	TrackNumber int32
-	Duration    uint32
-	Start       time.Time
+	Title        string
+	Artist       string
+	Album        string
+	AlbumArtist  string
+	TrackNumber  int32
+	DiscNumber   int32
+	Date         string
+	Duration     uint32
+	Start        time.Time
+	Instance     string   // name of the client that played it
+	Artists      []string // every artist tag, Artist is the first one
+	AlbumArtists []string
+	Genres       []string
+
+	// MusicBrainz identifiers
+	RecordingID    string
+	TrackID        string
+	ReleaseID      string
+	ReleaseGroupID string
+	ArtistIDs      []string
+}
+
+// Love is a request from another MPD client to love a song, or to unlove it
+// if Love is false.
+type Love struct {
+	Song Song
+	Love bool
 }
@@ -0,0 +1,139 @@
+package client
+
+import (
+	"encoding/json"
+	"log"
+	"time"
+
+	"github.com/softashell/mpd-scrobbler/client/mpd"
+)
+
+// StateStore keeps the encoded state of clients by name, so a restarted
+// scrobbler can pick up the song that was playing.
+type StateStore interface {
+	// State returns the stored state, or nil if there is none.
+	State(name string) ([]byte, error)
+	SetState(name string, state []byte) error
+}
+
+// state is the progress of the current song.
+type state struct {
+	Song      mpd.Song
+	Pos       mpd.Pos
+	Start     int
+	Playtime  int
+	StartTime time.Time
+	Submitted bool
+}
+
+// KeepState makes Watch save the current song and its progress to store
+// whenever the song changes and when it stops, and resume it when watching
+// starts if MPD is still playing the same song. It has to be called before
+// Watch.
+func (c *Client) KeepState(store StateStore) {
+	c.store = store
+}
+
+func (c *Client) state() state {
+	return state{
+		Song:      c.song,
+		Pos:       c.pos,
+		Start:     c.start,
+		Playtime:  c.playtime,
+		StartTime: c.starttime,
+		Submitted: c.submitted,
+	}
+}
+
+// sameListen tells if s and o only differ in how far the song got, which
+// isn't worth a write on every refresh.
+func (s state) sameListen(o state) bool {
+	return songsEqual(s.Song, o.Song) &&
+		s.Song.Id == o.Song.Id &&
+		s.Start == o.Start &&
+		s.StartTime.Equal(o.StartTime) &&
+		s.Submitted == o.Submitted
+}
+
+// saveState stores the state if another song or listen started since it was
+// last saved, or whenever it changed if final is set.
+func (c *Client) saveState(final bool) {
+	if c.store == nil {
+		return
+	}
+
+	s := c.state()
+	if c.saved != nil && s.sameListen(*c.saved) &&
+		(!final || s.Pos == c.saved.Pos && s.Playtime == c.saved.Playtime) {
+		return
+	}
+
+	data, err := json.Marshal(s)
+	if err != nil {
+		log.Printf("[%s] err(SaveState): %v\n", c.Name, err)
+		return
+	}
+	if err := c.store.SetState(c.Name, data); err != nil {
+		log.Printf("[%s] err(SaveState): %v\n", c.Name, err)
+		return
+	}
+	c.saved = &s
+}
+
+// restoreState resumes the stored state if MPD is still playing the same
+// song, going by its file and its id in the queue.
+func (c *Client) restoreState() {
+	if c.store == nil {
+		return
+	}
+
+	data, err := c.store.State(c.Name)
+	if err != nil {
+		log.Printf("[%s] err(RestoreState): %v\n", c.Name, err)
+		return
+	}
+	if data == nil {
+		return
+	}
+
+	var s state
+	if err := json.Unmarshal(data, &s); err != nil {
+		log.Printf("[%s] err(RestoreState): %v\n", c.Name, err)
+		return
+	}
+
+	var current mpd.Song
+	var playing bool
+	c.lock.Lock()
+	if !c.client.Closed {
+		if _, playing, err = c.client.CurrentPos(); err == nil && playing {
+			current, err = c.client.CurrentSong()
+		}
+	}
+	c.lock.Unlock()
+	if err != nil {
+		log.Printf("[%s] err(RestoreState): %v\n", c.Name, err)
+		return
+	}
+
+	if playing && c.resume(s, current) {
+		log.Printf("[%s] resuming %s - %s\n", c.Name, s.Song.Artist, s.Song.Title)
+	}
+}
+
+// resume restores s if current is the song it was saved for.
+func (c *Client) resume(s state, current mpd.Song) bool {
+	if s.Song.File == "" || s.Song.File != current.File || s.Song.Id != current.Id {
+		return false
+	}
+
+	c.song = s.Song
+	c.pos = s.Pos
+	c.start = s.Start
+	c.playtime = s.Playtime
+	c.starttime = s.StartTime
+	c.submitted = s.Submitted
+	c.resumed = true
+	c.saved = &s
+	return true
+}
@@ -0,0 +1,63 @@
+package client
+
+import "log"
+
+// ConfigureSticker sets the name of the sticker marking loved songs in the
+// MPD database, an empty name turns it off. Like Configure it is safe while
+// watching.
+func (c *Client) ConfigureSticker(name string) {
+	c.configLock.Lock()
+	defer c.configLock.Unlock()
+
+	c.sticker = name
+}
+
+// setLoved sets or removes the loved sticker of the song in file.
+func (c *Client) setLoved(file string, love bool) {
+	c.configLock.RLock()
+	sticker := c.sticker
+	c.configLock.RUnlock()
+
+	if sticker == "" || file == "" || isStream(file) {
+		return
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	var err error
+	if love {
+		err = c.client.StickerSet(file, sticker, "1")
+	} else {
+		err = c.client.StickerDelete(file, sticker)
+	}
+	if err != nil {
+		log.Printf("[%s] err(Sticker): %v\n", c.Name, err)
+	}
+}
+
+// ImportLoved sets the sticker on every song in the database of the MPD
+// server at addr that matches the artist and title of one of songs. It
+// returns the number of files marked.
+func ImportLoved(net, addr, pass, sticker string, songs []Song) (int, error) {
+	c, err := newClient(net, addr, pass, "")
+	if err != nil {
+		return 0, err
+	}
+	defer c.Close()
+
+	marked := 0
+	for _, song := range songs {
+		files, err := c.Find("artist", song.Artist, "title", song.Title)
+		if err != nil {
+			return marked, err
+		}
+		for _, file := range files {
+			if err := c.StickerSet(file, sticker, "1"); err != nil {
+				return marked, err
+			}
+			marked++
+		}
+	}
+	return marked, nil
+}
@@ -0,0 +1,98 @@
+package client
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/softashell/mpd-scrobbler/client/mpd"
+)
+
+// defaultStreamPattern splits the usual "Artist - Title" stream titles.
+var defaultStreamPattern = regexp.MustCompile("^(?P<artist>.+?) - (?P<title>.+)$")
+
+// StreamRule says how to read the titles of internet radio stations, which
+// usually only send an ICY StreamTitle with artist and title in one string.
+type StreamRule struct {
+	station  *regexp.Regexp   // matches the station name or URL, nil for all
+	patterns []*regexp.Regexp // with artist and title groups, tried in order
+	ignore   []*regexp.Regexp // titles of jingles, ads and the like
+}
+
+// NewStreamRule compiles a rule for the stations matching station, or all
+// stations if it is empty. Each pattern needs an artist and a title group,
+// without any patterns "Artist - Title" is assumed.
+func NewStreamRule(station string, patterns, ignore []string) (rule StreamRule, err error) {
+	if station != "" {
+		if rule.station, err = regexp.Compile(station); err != nil {
+			return
+		}
+	}
+
+	for _, p := range patterns {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return rule, err
+		}
+		if re.SubexpIndex("artist") < 0 || re.SubexpIndex("title") < 0 {
+			return rule, fmt.Errorf("pattern %q needs an artist and a title group", p)
+		}
+		rule.patterns = append(rule.patterns, re)
+	}
+	if len(rule.patterns) == 0 {
+		rule.patterns = []*regexp.Regexp{defaultStreamPattern}
+	}
+
+	for _, p := range ignore {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return rule, err
+		}
+		rule.ignore = append(rule.ignore, re)
+	}
+	return
+}
+
+func (r StreamRule) matches(song mpd.Song) bool {
+	return r.station == nil || r.station.MatchString(song.Name) || r.station.MatchString(song.File)
+}
+
+// isStream reports whether file is a remote URL rather than a library path.
+func isStream(file string) bool {
+	return strings.Contains(file, "://")
+}
+
+// streamSong fills in artist and title of a song played from a stream using
+// the first rule matching its station. It returns false if the song should
+// be ignored.
+func (c *Client) streamSong(song mpd.Song) (mpd.Song, bool) {
+	c.configLock.RLock()
+	streams := c.streams
+	c.configLock.RUnlock()
+
+	rule := StreamRule{patterns: []*regexp.Regexp{defaultStreamPattern}}
+	for _, r := range streams {
+		if r.matches(song) {
+			rule = r
+			break
+		}
+	}
+
+	for _, re := range rule.ignore {
+		if re.MatchString(song.Title) {
+			return song, false
+		}
+	}
+
+	if song.Artist != "" {
+		return song, true
+	}
+	for _, re := range rule.patterns {
+		if m := re.FindStringSubmatch(song.Title); m != nil {
+			song.Artist = strings.TrimSpace(m[re.SubexpIndex("artist")])
+			song.Title = strings.TrimSpace(m[re.SubexpIndex("title")])
+			break
+		}
+	}
+	return song, true
+}
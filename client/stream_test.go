@@ -0,0 +1,47 @@
+package client
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/softashell/mpd-scrobbler/client/mpd"
+)
+
+func TestStreamSong(t *testing.T) {
+	assert := assert.New(t)
+
+	paradise, err := NewStreamRule("Paradise", []string{"^(?P<title>.+) by (?P<artist>.+)$"}, nil)
+	assert.Nil(err)
+	all, err := NewStreamRule("", nil, []string{"(?i)advert", "^$"})
+	assert.Nil(err)
+	c := &Client{streams: []StreamRule{paradise, all}}
+
+	for _, tc := range []struct {
+		song          mpd.Song
+		artist, title string
+		ok            bool
+	}{
+		{mpd.Song{Name: "Radio Paradise", Title: "Song by Band"}, "Band", "Song", true},
+		{mpd.Song{Name: "Other FM", Title: "Band - Song - Live"}, "Band", "Song - Live", true},
+		{mpd.Song{Name: "Other FM", Title: "Advertisement"}, "", "", false},
+		{mpd.Song{Name: "Other FM", Title: ""}, "", "", false},
+		{mpd.Song{Name: "Other FM", Title: "Station ID"}, "", "Station ID", true},
+		{mpd.Song{Name: "Other FM", Artist: "Band", Title: "Song"}, "Band", "Song", true},
+	} {
+		song, ok := c.streamSong(tc.song)
+		assert.Equal(tc.ok, ok, tc.song.Title)
+		if ok {
+			assert.Equal(tc.artist, song.Artist, tc.song.Title)
+			assert.Equal(tc.title, song.Title, tc.song.Title)
+		}
+	}
+}
+
+func TestNewStreamRule(t *testing.T) {
+	_, err := NewStreamRule("", []string{"^(?P<artist>.+): (.+)$"}, nil)
+	assert.EqualError(t, err, `pattern "^(?P<artist>.+): (.+)$" needs an artist and a title group`)
+
+	_, err = NewStreamRule("(", nil, nil)
+	assert.NotNil(t, err)
+}
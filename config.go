@@ -0,0 +1,397 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+
+	"github.com/softashell/mpd-scrobbler/client"
+	"github.com/softashell/mpd-scrobbler/scrobble"
+)
+
+// Config is the parsed config file. Every top-level table other than [mpd],
+// [scrobble] and [[stream]] configures a scrobbling service named after the
+// table. Several mpd servers can be watched by using [[mpd]] instead of [mpd].
+type Config struct {
+	MPD      []MPDConfig
+	Scrobble ScrobbleConfig
+	Streams  []StreamConfig
+	Services map[string]scrobble.Config
+}
+
+// MPDConfig describes one watched mpd server. Settings from [scrobble] can be
+// overridden per server.
+type MPDConfig struct {
+	Name            string `toml:"name"` // defaults to "mpd", or host for [[mpd]]
+	Host            string `toml:"host"` // see client.ParseHost
+	Port            int    `toml:"port"`
+	Password        string `toml:"password"`
+	PasswordCommand string `toml:"password_command"`
+	PasswordFile    string `toml:"password_file"`
+	Partition       string `toml:"partition"` // or allPartitions
+	Channel         string `toml:"channel"`   // for love/unlove messages, none if empty
+	Sticker         string `toml:"sticker"`   // marks loved songs, none if empty
+	ScrobbleConfig
+}
+
+type ScrobbleConfig struct {
+	SubmitTime        int  `toml:"submit_time"`
+	SubmitPercentage  int  `toml:"submit_percentage"`
+	SubmitMinDuration int  `toml:"submit_min_duration"`
+	TitleHack         bool `toml:"title_hack"`
+	Duration          bool `toml:"duration"`
+}
+
+// StreamConfig says how titles of internet radio stations are read, see
+// client.NewStreamRule.
+type StreamConfig struct {
+	Station  string   `toml:"station"`
+	Patterns []string `toml:"patterns"`
+	Ignore   []string `toml:"ignore"`
+}
+
+// defaultConfig returns the built-in defaults.
+func defaultConfig() *Config {
+	return &Config{
+		Scrobble: ScrobbleConfig{
+			SubmitTime:        client.SubmitTime,
+			SubmitPercentage:  client.SubmitPercentage,
+			SubmitMinDuration: client.SubmitMinDuration,
+			TitleHack:         client.TitleHack,
+			Duration:          true,
+		},
+		Services: map[string]scrobble.Config{},
+	}
+}
+
+// defaultMPD returns the settings of an mpd server before its table is
+// applied, with the connection taken from MPD_HOST and MPD_PORT if they are
+// set.
+func (conf *Config) defaultMPD() MPDConfig {
+	m := MPDConfig{
+		Name:           "mpd",
+		Host:           "127.0.0.1",
+		Port:           6600,
+		Channel:        "mpdscribble",
+		ScrobbleConfig: conf.Scrobble,
+	}
+	if h := os.Getenv("MPD_HOST"); h != "" {
+		m.Host = h
+	}
+	if p, err := strconv.Atoi(os.Getenv("MPD_PORT")); err == nil {
+		m.Port = p
+	}
+	return m
+}
+
+// loadConfig reads the config file at path on top of the defaults. The
+// result still has to be validated once flags are applied.
+func loadConfig(path string) (*Config, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	conf, err := parseConfig(string(data))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return conf, nil
+}
+
+func parseConfig(data string) (*Config, error) {
+	conf := defaultConfig()
+
+	var tables map[string]toml.Primitive
+	md, err := toml.Decode(data, &tables)
+	if err != nil {
+		return nil, err
+	}
+
+	// servers inherit [scrobble], so it has to be decoded first
+	if table, ok := tables["scrobble"]; ok {
+		if err := md.PrimitiveDecode(table, &conf.Scrobble); err != nil {
+			return nil, fmt.Errorf("[scrobble]: %v", err)
+		}
+	}
+
+	services := map[string]ServiceConfig{}
+	for name, table := range tables {
+		switch name {
+		case "mpd":
+			conf.MPD, err = conf.decodeMPD(md, table)
+		case "scrobble":
+		case "stream":
+			err = md.PrimitiveDecode(table, &conf.Streams)
+		default:
+			var service ServiceConfig
+			err = md.PrimitiveDecode(table, &service)
+			services[name] = service
+		}
+		if err != nil {
+			return nil, fmt.Errorf("[%s]: %v", name, err)
+		}
+	}
+
+	if undecoded := md.Undecoded(); len(undecoded) > 0 {
+		keys := make([]string, len(undecoded))
+		for i, key := range undecoded {
+			keys[i] = key.String()
+		}
+		return nil, fmt.Errorf("unknown keys: %s", strings.Join(keys, ", "))
+	}
+
+	if len(conf.MPD) == 0 {
+		conf.MPD = []MPDConfig{conf.defaultMPD()}
+	}
+
+	for i := range conf.MPD {
+		m := &conf.MPD[i]
+		m.Password, err = resolveSecret("password", m.Password, m.PasswordCommand, m.PasswordFile)
+		if err != nil {
+			return nil, fmt.Errorf("[%s]: %v", m.Name, err)
+		}
+	}
+
+	for name, service := range services {
+		if conf.Services[name], err = service.resolve(); err != nil {
+			return nil, fmt.Errorf("[%s]: %v", name, err)
+		}
+	}
+
+	return conf, nil
+}
+
+// decodeMPD decodes either a single [mpd] table or an [[mpd]] array.
+func (conf *Config) decodeMPD(md toml.MetaData, table toml.Primitive) ([]MPDConfig, error) {
+	if md.Type("mpd") != "ArrayHash" {
+		m := conf.defaultMPD()
+		if err := md.PrimitiveDecode(table, &m); err != nil {
+			return nil, err
+		}
+		return []MPDConfig{m}, nil
+	}
+
+	var tables []toml.Primitive
+	if err := md.PrimitiveDecode(table, &tables); err != nil {
+		return nil, err
+	}
+
+	servers := make([]MPDConfig, len(tables))
+	for i, t := range tables {
+		servers[i] = conf.defaultMPD()
+		servers[i].Name = ""
+		if err := md.PrimitiveDecode(t, &servers[i]); err != nil {
+			return nil, err
+		}
+		if servers[i].Name == "" {
+			servers[i].Name = servers[i].Host
+		}
+	}
+	return servers, nil
+}
+
+func (conf *Config) validate() error {
+	if err := conf.Scrobble.validate(); err != nil {
+		return fmt.Errorf("[scrobble]: %v", err)
+	}
+
+	names := map[string]bool{}
+	for _, m := range conf.MPD {
+		if names[m.Name] {
+			return fmt.Errorf("[%s]: duplicate mpd name", m.Name)
+		}
+		names[m.Name] = true
+
+		if err := m.validate(); err != nil {
+			return fmt.Errorf("[%s]: %v", m.Name, err)
+		}
+	}
+
+	if _, err := conf.streamRules(); err != nil {
+		return err
+	}
+
+	for _, name := range conf.serviceNames() {
+		// queues and internal buckets are told apart from services by dots,
+		// e.g. "lastfm.dead" or ".sessions"
+		if strings.Contains(name, ".") {
+			return fmt.Errorf("[%q]: service names must not contain a dot", name)
+		}
+		if err := validateService(conf.Services[name]); err != nil {
+			return fmt.Errorf("[%s]: %v", name, err)
+		}
+	}
+	return nil
+}
+
+func (m MPDConfig) validate() error {
+	if m.Host == "" {
+		return fmt.Errorf("host must not be empty")
+	}
+	if m.Port <= 0 || m.Port > 65535 {
+		return fmt.Errorf("invalid port %d", m.Port)
+	}
+	return m.ScrobbleConfig.validate()
+}
+
+func (s ScrobbleConfig) validate() error {
+	switch {
+	case s.SubmitTime < 0:
+		return fmt.Errorf("submit_time must not be negative")
+	case s.SubmitPercentage < 0 || s.SubmitPercentage > 100:
+		return fmt.Errorf("submit_percentage must be between 0 and 100")
+	case s.SubmitMinDuration < 0:
+		return fmt.Errorf("submit_min_duration must not be negative")
+	}
+	return nil
+}
+
+// streamRules compiles the [[stream]] tables in order.
+func (conf *Config) streamRules() ([]client.StreamRule, error) {
+	rules := make([]client.StreamRule, len(conf.Streams))
+	for i, s := range conf.Streams {
+		var err error
+		if rules[i], err = client.NewStreamRule(s.Station, s.Patterns, s.Ignore); err != nil {
+			return nil, fmt.Errorf("[stream %d]: %v", i+1, err)
+		}
+	}
+	return rules, nil
+}
+
+func validateService(s scrobble.Config) error {
+	switch s.Type {
+	case "", scrobble.TypeLastfm:
+		if s.Key == "" || s.Secret == "" {
+			return fmt.Errorf("key and secret are required")
+		}
+		if s.Token != "" {
+			return fmt.Errorf("token is only used by listenbrainz services")
+		}
+	case scrobble.TypeListenBrainz:
+		if s.Token == "" {
+			return fmt.Errorf("token is required")
+		}
+		if s.Key != "" || s.Secret != "" || s.Username != "" || s.Password != "" {
+			return fmt.Errorf("listenbrainz services only use token and uri")
+		}
+	default:
+		return fmt.Errorf("unknown type %q", s.Type)
+	}
+	if _, err := s.MaxScrobbleAge(); err != nil {
+		return err
+	}
+	_, err := s.DuplicateWindow()
+	return err
+}
+
+func (conf *Config) serviceNames() []string {
+	names := make([]string, 0, len(conf.Services))
+	for name := range conf.Services {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// applyFlags overrides the config with flags given on the command line.
+// Connection flags can only be used with a single mpd server.
+func (conf *Config) applyFlags() (err error) {
+	flag.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "host", "port", "pass":
+			if len(conf.MPD) > 1 {
+				err = fmt.Errorf("-%s can't be used with several [[mpd]] servers", f.Name)
+			}
+		}
+	})
+	if err != nil {
+		return
+	}
+
+	applyScrobbleFlags(&conf.Scrobble)
+	for i := range conf.MPD {
+		m := &conf.MPD[i]
+		applyScrobbleFlags(&m.ScrobbleConfig)
+		flag.Visit(func(f *flag.Flag) {
+			switch f.Name {
+			case "host":
+				m.Host = *host
+			case "port":
+				m.Port = *port
+			case "pass":
+				m.Password, err = expandEnv("-pass", *pass)
+			}
+		})
+	}
+	return
+}
+
+func applyScrobbleFlags(s *ScrobbleConfig) {
+	flag.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "duration":
+			s.Duration = *duration
+		case "submittime":
+			s.SubmitTime = *submitTime
+		case "submitpercentage":
+			s.SubmitPercentage = *submitPercentage
+		case "submitminduration":
+			s.SubmitMinDuration = *submitMinDuration
+		case "titlehack":
+			s.TitleHack = *titleHack
+		}
+	})
+}
+
+// allPartitions as partition watches every partition of a server.
+const allPartitions = "*"
+
+// server returns the settings of the named mpd server, or nil.
+func (conf *Config) server(name string) *MPDConfig {
+	for i := range conf.MPD {
+		if conf.MPD[i].Name == name {
+			return &conf.MPD[i]
+		}
+	}
+	return nil
+}
+
+// instance returns the settings of the mpd server that a client named name
+// was started for, or nil. Clients watching all partitions of a server are
+// named "server/partition".
+func (conf *Config) instance(name string) *MPDConfig {
+	if m := conf.server(name); m != nil && m.Partition != allPartitions {
+		return m
+	}
+	for i := range conf.MPD {
+		m := &conf.MPD[i]
+		if m.Partition == allPartitions && strings.HasPrefix(name, m.Name+"/") {
+			return m
+		}
+	}
+	return nil
+}
+
+// sameConnection reports whether m connects to the same server and
+// partition as o, and subscribes to the same channel.
+func (m MPDConfig) sameConnection(o MPDConfig) bool {
+	return m.Host == o.Host && m.Port == o.Port && m.Password == o.Password &&
+		m.Partition == o.Partition && m.Channel == o.Channel
+}
+
+// dialArgs returns the network, address and password to connect to mpd.
+// A password given in host takes precedence.
+func (m MPDConfig) dialArgs() (network, addr, password string) {
+	network, addr, password = client.ParseHost(m.Host, m.Port)
+	if password == "" {
+		password = m.Password
+	}
+	return
+}
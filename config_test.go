@@ -0,0 +1,131 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/softashell/mpd-scrobbler/scrobble"
+)
+
+func TestParseConfig(t *testing.T) {
+	assert := assert.New(t)
+
+	conf, err := parseConfig(`
+[mpd]
+host = "music.local"
+
+[scrobble]
+submit_percentage = 75
+title_hack = true
+
+[lastfm]
+key = "k"
+secret = "s"
+username = "john"
+password = "p"
+
+[listenbrainz]
+type = "listenbrainz"
+token = "t"
+`)
+	assert.Nil(err)
+	assert.Nil(conf.validate())
+
+	if assert.Len(conf.MPD, 1) {
+		m := conf.MPD[0]
+		assert.Equal("mpd", m.Name)
+		assert.Equal("music.local", m.Host)
+		assert.Equal(6600, m.Port)
+		assert.Equal(conf.Scrobble, m.ScrobbleConfig)
+	}
+	assert.Equal(75, conf.Scrobble.SubmitPercentage)
+	assert.Equal(120, conf.Scrobble.SubmitTime)
+	assert.True(conf.Scrobble.TitleHack)
+	assert.True(conf.Scrobble.Duration)
+
+	assert.Equal([]string{"lastfm", "listenbrainz"}, conf.serviceNames())
+	assert.Equal(scrobble.Config{Key: "k", Secret: "s", Username: "john", Password: "p"}, conf.Services["lastfm"])
+	assert.Equal(scrobble.Config{Type: "listenbrainz", Token: "t"}, conf.Services["listenbrainz"])
+}
+
+func TestParseConfigServers(t *testing.T) {
+	assert := assert.New(t)
+
+	conf, err := parseConfig(`
+[scrobble]
+submit_time = 60
+
+[[mpd]]
+host = "living-room"
+
+[[mpd]]
+name = "office"
+host = "10.0.0.2"
+port = 6601
+submit_time = 30
+duration = false
+`)
+	assert.Nil(err)
+	assert.Nil(conf.validate())
+
+	if assert.Len(conf.MPD, 2) {
+		assert.Equal("living-room", conf.MPD[0].Name)
+		assert.Equal(60, conf.MPD[0].SubmitTime)
+		assert.True(conf.MPD[0].Duration)
+
+		assert.Equal("office", conf.MPD[1].Name)
+		assert.Equal(6601, conf.MPD[1].Port)
+		assert.Equal(30, conf.MPD[1].SubmitTime)
+		assert.False(conf.MPD[1].Duration)
+	}
+	assert.Equal(&conf.MPD[1], conf.server("office"))
+	assert.Nil(conf.server("mpd"))
+}
+
+func TestParseConfigErrors(t *testing.T) {
+	for conf, msg := range map[string]string{
+		"[lastfm]\nkey = \"k\"\nsecret = \"s\"\nusrname = \"john\"\n": "unknown keys: lastfm.usrname",
+		"[mpd]\nport = \"6600\"\n":                                    "[mpd]: toml: cannot load TOML value of type string into a Go integer",
+		"[lastfm]\nkey = \"k\"\n":                                     "[lastfm]: key and secret are required",
+		"[lb]\ntype = \"listenbrainz\"\n":                             "[lb]: token is required",
+		"[x]\ntype = \"spotify\"\n":                                   "[x]: unknown type \"spotify\"",
+		"[scrobble]\nsubmit_percentage = 150\n":                       "[scrobble]: submit_percentage must be between 0 and 100",
+		"[[mpd]]\nhost = \"a\"\n[[mpd]]\nhost = \"a\"\n":              "[a]: duplicate mpd name",
+		"[\"lastfm.dead\"]\nkey = \"k\"\nsecret = \"s\"\n":            "[\"lastfm.dead\"]: service names must not contain a dot",
+		"[[stream]]\npatterns = [\"(.+) - (.+)\"]\n":                  "[stream 1]: pattern \"(.+) - (.+)\" needs an artist and a title group",
+	} {
+		c, err := parseConfig(conf)
+		if err == nil {
+			err = c.validate()
+		}
+		if assert.NotNil(t, err, conf) {
+			assert.Equal(t, msg, err.Error(), conf)
+		}
+	}
+}
+
+func TestConfigInstance(t *testing.T) {
+	assert := assert.New(t)
+
+	conf, err := parseConfig(`
+[[mpd]]
+name = "home"
+host = "home.local"
+partition = "*"
+
+[[mpd]]
+name = "bedroom"
+host = "home.local"
+partition = "bedroom"
+`)
+	assert.Nil(err)
+	assert.Nil(conf.validate())
+
+	assert.Equal(&conf.MPD[0], conf.instance("home/default"))
+	assert.Equal(&conf.MPD[0], conf.instance("home/bedroom"))
+	assert.Equal(&conf.MPD[1], conf.instance("bedroom"))
+	assert.Nil(conf.instance("home"))
+	assert.Nil(conf.instance("office/default"))
+	assert.Equal(map[string]string{"bedroom": "bedroom"}, partitions(conf.MPD[1]))
+}
@@ -0,0 +1,219 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/softashell/mpd-scrobbler/scrobble"
+)
+
+// guessFormat picks the format from the extension of path.
+func guessFormat(path string) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".csv":
+		return scrobble.FormatCSV
+	case ".log":
+		return scrobble.FormatScrobblerLog
+	}
+	return scrobble.FormatJSONLines
+}
+
+// parseDate parses a day in local time, or returns def if s is empty.
+func parseDate(s string, def time.Time) (time.Time, error) {
+	if s == "" {
+		return def, nil
+	}
+	return time.ParseInLocation("2006-01-02", s, time.Local)
+}
+
+func exportCommand(db scrobble.Database, args []string) error {
+	fs := flag.NewFlagSet("export", flag.ContinueOnError)
+	format := fs.String("format", scrobble.FormatJSONLines, "jsonl, csv or scrobbler-log")
+	queue := fs.String("queue", "", "export this queue, e.g. lastfm.dead, instead of the history")
+	from := fs.String("from", "", "first day to export from the history, as YYYY-MM-DD")
+	to := fs.String("to", "", "last day to export from the history, as YYYY-MM-DD")
+	status := fs.String("status", scrobble.StatusAccepted, "export history entries with these comma separated statuses, or all")
+	service := fs.String("service", "", "only export history entries of this service")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		return fmt.Errorf("usage: export [-format <format>] [-queue <queue> | -from <day> -to <day> -status <status> -service <service>]")
+	}
+
+	enc, err := scrobble.NewEncoder(os.Stdout, *format)
+	if err != nil {
+		return err
+	}
+
+	var tracks []scrobble.Track
+	if *queue != "" {
+		q, err := db.Queue([]byte(*queue))
+		if err != nil {
+			return err
+		}
+		// written only once the database is released, as stdout may block
+		// while the daemon waits to write
+		err = q.Each(func(id uint64, track scrobble.Track) error {
+			tracks = append(tracks, track)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	} else {
+		start, err := parseDate(*from, time.Unix(0, 0))
+		if err != nil {
+			return err
+		}
+		end, err := parseDate(*to, time.Now())
+		if err != nil {
+			return err
+		}
+		if *to != "" {
+			end = end.AddDate(0, 0, 1)
+		}
+
+		entries, err := db.History(start, end)
+		if err != nil {
+			return err
+		}
+		statuses := parseStatuses(*status)
+		for _, entry := range entries {
+			if entryMatches(entry, *service, statuses) {
+				tracks = append(tracks, entry.Track)
+			}
+		}
+	}
+
+	for _, track := range tracks {
+		if err := enc.Encode(track); err != nil {
+			return err
+		}
+	}
+	return enc.Flush()
+}
+
+// parseStatuses returns the set of comma separated statuses in s, or nil for
+// "all".
+func parseStatuses(s string) map[string]bool {
+	if s == "all" {
+		return nil
+	}
+	statuses := map[string]bool{}
+	for _, status := range strings.Split(s, ",") {
+		statuses[strings.TrimSpace(status)] = true
+	}
+	return statuses
+}
+
+// entryMatches tells if entry has one of statuses for service, or for any
+// service if service is empty. A nil statuses matches every status.
+func entryMatches(entry scrobble.HistoryEntry, service string, statuses map[string]bool) bool {
+	for name, result := range entry.Results {
+		if service != "" && name != service {
+			continue
+		}
+		if statuses == nil || statuses[result.Status] {
+			return true
+		}
+	}
+	return false
+}
+
+func importCommand(db scrobble.Database, conf *Config, args []string) error {
+	fs := flag.NewFlagSet("import", flag.ContinueOnError)
+	format := fs.String("format", "", "jsonl, csv or scrobbler-log, guessed from the file name if not set")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() < 1 || fs.NArg() > 2 {
+		return fmt.Errorf("usage: import [-format <format>] <service> [<file>]")
+	}
+
+	name := fs.Arg(0)
+	service, ok := conf.Services[name]
+	if !ok {
+		return fmt.Errorf("unknown service %q", name)
+	}
+
+	var r io.Reader = os.Stdin
+	if path := fs.Arg(1); path != "" {
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		r = f
+
+		if *format == "" {
+			*format = guessFormat(path)
+		}
+	}
+	if *format == "" {
+		*format = scrobble.FormatJSONLines
+	}
+
+	dec, err := scrobble.NewDecoder(r, *format)
+	if err != nil {
+		return err
+	}
+
+	queue, err := db.Queue([]byte(name))
+	if err != nil {
+		return err
+	}
+
+	// the service would only move older tracks to its expired queue
+	maxAge, err := service.MaxScrobbleAge()
+	if err != nil {
+		return err
+	}
+	var cutoff time.Time
+	if maxAge > 0 {
+		cutoff = time.Now().Add(-maxAge)
+	}
+
+	var tracks []scrobble.Track
+	tooOld := 0
+	for {
+		track, err := dec.Decode()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		if track.Timestamp.Before(cutoff) {
+			tooOld++
+			continue
+		}
+		tracks = append(tracks, track)
+	}
+
+	// one transaction, the daemon may be using the database
+	if err := queue.Enqueue(tracks...); err != nil {
+		return err
+	}
+
+	fmt.Printf("Queued %d tracks for %s\n", len(tracks), name)
+	if tooOld > 0 {
+		fmt.Printf("Skipped %d tracks older than %s\n", tooOld, formatAge(maxAge))
+	}
+	return nil
+}
+
+// formatAge prints an age the way max_age is usually written, in days when
+// it is a whole number of them.
+func formatAge(d time.Duration) string {
+	if day := 24 * time.Hour; d%day == 0 {
+		return fmt.Sprintf("%d days", d/day)
+	}
+	return d.String()
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/softashell/mpd-scrobbler/scrobble"
+)
+
+func TestEntryMatches(t *testing.T) {
+	assert := assert.New(t)
+
+	entry := scrobble.HistoryEntry{Results: map[string]scrobble.HistoryResult{
+		"lastfm": {Status: scrobble.StatusRejected},
+		"lb":     {Status: scrobble.StatusAccepted},
+	}}
+
+	accepted := parseStatuses(scrobble.StatusAccepted)
+	assert.True(entryMatches(entry, "", accepted))
+	assert.True(entryMatches(entry, "lb", accepted))
+	assert.False(entryMatches(entry, "lastfm", accepted))
+	assert.True(entryMatches(entry, "lastfm", parseStatuses("ignored, rejected")))
+	assert.True(entryMatches(entry, "lastfm", parseStatuses("all")))
+	assert.False(entryMatches(entry, "other", parseStatuses("all")))
+}
+
+func TestFormatAge(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal("14 days", formatAge(14*24*time.Hour))
+	assert.Equal("36h0m0s", formatAge(36*time.Hour))
+}
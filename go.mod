@@ -1,5 +1,7 @@
 module github.com/softashell/mpd-scrobbler
 
+go 1.17
+
 require (
 	github.com/BurntSushi/toml v0.3.1
 	github.com/davecgh/go-spew v1.1.1 // indirect
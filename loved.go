@@ -0,0 +1,51 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/softashell/mpd-scrobbler/client"
+	"github.com/softashell/mpd-scrobbler/scrobble"
+)
+
+func importLovedCommand(conf *Config, args []string) error {
+	if len(args) < 1 || len(args) > 2 {
+		return fmt.Errorf("usage: import-loved <service> [<mpd>]")
+	}
+
+	name := args[0]
+	service, ok := conf.Services[name]
+	if !ok {
+		return fmt.Errorf("unknown service %q", name)
+	}
+
+	m := &conf.MPD[0]
+	if len(args) == 2 {
+		if m = conf.server(args[1]); m == nil {
+			return fmt.Errorf("unknown mpd server %q", args[1])
+		}
+	} else if len(conf.MPD) > 1 {
+		return fmt.Errorf("several mpd servers are configured, pick one")
+	}
+	if m.Sticker == "" {
+		return fmt.Errorf("[%s]: sticker has to be set to import loved tracks", m.Name)
+	}
+
+	tracks, err := scrobble.LovedTracks(service)
+	if err != nil {
+		return fmt.Errorf("%s: %v", name, err)
+	}
+
+	songs := make([]client.Song, len(tracks))
+	for i, track := range tracks {
+		songs[i] = client.Song{Title: track.Title, Artist: track.Artist}
+	}
+
+	network, addr, password := m.dialArgs()
+	marked, err := client.ImportLoved(network, addr, password, m.Sticker, songs)
+	if err != nil {
+		return fmt.Errorf("[%s]: %v", m.Name, err)
+	}
+
+	fmt.Printf("Marked %d files for %d loved tracks on %s\n", marked, len(tracks), name)
+	return nil
+}
@@ -2,30 +2,28 @@ package main
 
 import (
 	"flag"
+	"fmt"
 	"log"
 	"os"
 	"os/signal"
-	"sync"
 	"syscall"
 	"time"
 
-	"github.com/BurntSushi/toml"
-
 	"github.com/softashell/mpd-scrobbler/client"
 	"github.com/softashell/mpd-scrobbler/scrobble"
 )
 
 const (
-	// polling interval
-	sleepTime = 5 * time.Second
+	// elapsed time refresh interval, song changes come from idle events
+	sleepTime = 30 * time.Second
 )
 
 var (
 	config   = flag.String("config", "./config.toml", "path to config file")
 	dbPath   = flag.String("db", "./scrobble.db", "path to database for caching")
-	host     = flag.String("host", "127.0.0.1", "mpd connection address")
-	port     = flag.String("port", "6600", "mpd connection port")
-	pass     = flag.String("pass", "", "mpd password")
+	host     = flag.String("host", "127.0.0.1", "mpd [password@]host, socket path or @abstract socket, overrides $MPD_HOST")
+	port     = flag.Int("port", 6600, "mpd connection port, overrides $MPD_PORT")
+	pass     = flag.String("pass", "", "mpd password, ${ENV} references are expanded")
 	duration = flag.Bool("duration", true, "should we send tracks durations?")
 
 	submitTime = flag.Int(
@@ -46,107 +44,224 @@ var (
 		"attempt to extract artist from title if it's not specified")
 )
 
-func catchInterrupt() {
-	c := make(chan os.Signal, 1)
-	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
-	s := <-c
-	log.Printf("caught %s: shutting down", s)
+// readConfig loads the config file and applies the command line flags.
+func readConfig() (*Config, error) {
+	conf, err := loadConfig(*config)
+	if err != nil {
+		return nil, err
+	}
+	if err := conf.applyFlags(); err != nil {
+		return nil, err
+	}
+	return conf, conf.validate()
+}
+
+// reload re-reads the config and applies it without losing track of the
+// current songs. Servers watched with all their partitions get clients for
+// partitions created since they were last listed.
+func reload(conf *Config, w *watchers, svcs *services) *Config {
+	newConf, err := readConfig()
+	if err != nil {
+		log.Println("reload failed:", err)
+		return conf
+	}
+
+	// already validated by readConfig
+	streams, _ := newConf.streamRules()
+
+	for _, m := range newConf.MPD {
+		if conf.server(m.Name) == nil {
+			log.Printf("[%s] new mpd server, restart to watch it", m.Name)
+		}
+	}
+	for name, c := range w.clients {
+		m := newConf.instance(name)
+		if m == nil {
+			log.Printf("[%s] mpd server removed, restart to stop watching it", name)
+			continue
+		}
+		if old := conf.instance(name); !m.sameConnection(*old) {
+			log.Printf("[%s] mpd connection settings changed, restart to apply them", name)
+		}
+		c.Configure(m.SubmitTime, m.SubmitPercentage, m.SubmitMinDuration, m.TitleHack)
+		c.ConfigureStreams(streams)
+		c.ConfigureSticker(m.Sticker)
+	}
+
+	for _, m := range newConf.MPD {
+		if old := conf.server(m.Name); m.Partition == allPartitions && old != nil && m.sameConnection(*old) {
+			w.addPartitions(m, streams)
+		}
+	}
+
+	if err := svcs.apply(newConf.Services); err != nil {
+		log.Println("reload:", err)
+	}
+
+	log.Println("reloaded config")
+	return newConf
+}
+
+// partitions returns the partitions to watch on m by client name. When all
+// partitions are watched they are listed at startup, falling back to the
+// default partition if the server can't be reached, and again on reload.
+func partitions(m MPDConfig) map[string]string {
+	if m.Partition != allPartitions {
+		return map[string]string{m.Name: m.Partition}
+	}
+
+	names, err := client.Partitions(m.dialArgs())
+	if err != nil {
+		log.Printf("[%s] listing partitions failed, watching the default one until the config is reloaded: %v", m.Name, err)
+		names = []string{"default"}
+	}
+
+	clients := map[string]string{}
+	for _, name := range names {
+		clients[m.Name+"/"+name] = name
+	}
+	return clients
+}
+
+func songTrack(s client.Song) scrobble.Track {
+	return scrobble.Track{
+		Title:          s.Title,
+		Artist:         s.Artist,
+		Album:          s.Album,
+		AlbumArtist:    s.AlbumArtist,
+		TrackNumber:    s.TrackNumber,
+		DiscNumber:     s.DiscNumber,
+		Date:           s.Date,
+		Duration:       s.Duration,
+		Timestamp:      s.Start,
+		Instance:       s.Instance,
+		Artists:        s.Artists,
+		AlbumArtists:   s.AlbumArtists,
+		Genres:         s.Genres,
+		RecordingID:    s.RecordingID,
+		TrackID:        s.TrackID,
+		ReleaseID:      s.ReleaseID,
+		ReleaseGroupID: s.ReleaseGroupID,
+		ArtistIDs:      s.ArtistIDs,
+	}
 }
 
 func init() {
 	log.SetFlags(log.Lshortfile)
 }
 
+func usage() {
+	fmt.Fprintf(flag.CommandLine.Output(), `Usage: %s [flags] [command]
+
+Commands:
+  auth <service>	authorize a Last.fm compatible service via the browser
+			instead of storing its password
+  queue list|show|delete|retry|purge ...
+			inspect and fix the queues, also while running
+  export [-format <format>] [-queue <queue>] [-status <status>]
+			write the history or a queue to stdout as jsonl, csv
+			or scrobbler-log
+  import [-format <format>] <service> [<file>]
+			queue tracks from a file or stdin for a service
+  import-loved <service> [<mpd>]
+			mark the songs loved on a Last.fm compatible service
+			with the sticker of an mpd server
+
+Flags:
+`, os.Args[0])
+	flag.PrintDefaults()
+}
+
 func main() {
+	flag.Usage = usage
 	flag.Parse()
 
-	c, err := client.Dial("tcp", *host+":"+*port, *pass)
+	db, err := scrobble.Open(*dbPath)
 	if err != nil {
 		log.Fatal(err)
 	}
-	defer c.Close()
-
-	c.SubmitTime = *submitTime
-	c.SubmitPercentage = *submitPercentage
-	c.SubmitMinDuration = *submitMinDuration
-	c.TitleHack = *titleHack
+	defer db.Close()
 
-	db, err := scrobble.Open(*dbPath)
+	conf, err := readConfig()
 	if err != nil {
 		log.Fatal(err)
 	}
-	defer db.Close()
 
-	var conf map[string]map[string]string
-	if _, err := toml.DecodeFile(*config, &conf); err != nil {
+	switch cmd := flag.Arg(0); cmd {
+	case "":
+	case "auth":
+		if err := authCommand(db, conf, flag.Args()[1:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	case "queue":
+		if err := queueCommand(db, conf, flag.Args()[1:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	case "export":
+		if err := exportCommand(db, flag.Args()[1:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	case "import":
+		if err := importCommand(db, conf, flag.Args()[1:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	case "import-loved":
+		if err := importLovedCommand(conf, flag.Args()[1:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	default:
+		log.Fatalf("unknown command %q", cmd)
+	}
+
+	svcs := newServices(db)
+	defer svcs.close()
+
+	if err := svcs.apply(conf.Services); err != nil {
 		log.Fatal(err)
 	}
 
-	apis := []scrobble.Scrobbler{}
-	for k, v := range conf {
-		api, err := scrobble.New(db, k, v["key"], v["secret"], v["username"], v["password"], v["uri"])
-		if err != nil {
-			log.Fatal(k, " ", err)
+	streams, _ := conf.streamRules()
+
+	w := newWatchers(db)
+	defer w.close()
+
+	for _, m := range conf.MPD {
+		for name, partition := range partitions(m) {
+			w.start(name, m, partition, streams)
 		}
+	}
 
-		apis = append(apis, api)
-	}
-
-	toSubmit := make(chan client.Song)
-	nowPlaying := make(chan client.Song)
-
-	quitchan := make(chan struct{})
-
-	var wg sync.WaitGroup
-
-	wg.Add(1)
-
-	go c.Watch(sleepTime, toSubmit, nowPlaying)
-
-	go func() {
-		for {
-			select {
-			case s := <-nowPlaying:
-				if !*duration {
-					s.Duration = 0
-				}
-				for _, api := range apis {
-					err := api.NowPlaying(
-						s.Title,
-						s.Artist,
-						s.Album,
-						s.AlbumArtist,
-						s.TrackNumber,
-						s.Duration)
-					if err != nil {
-						log.Printf("[%s] err(NowPlaying): %s\n", api.Name(), err)
-					}
-				}
-
-			case s := <-toSubmit:
-				for _, api := range apis {
-					err := api.Scrobble(
-						s.Title,
-						s.Artist,
-						s.Album,
-						s.AlbumArtist,
-						s.TrackNumber,
-						s.Duration,
-						s.Start)
-					if err != nil {
-						log.Printf("[%s] err(Scrobble): %s\n", api.Name(), err)
-					}
-				}
-
-			case <-quitchan:
-				wg.Done()
-				return
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM, syscall.SIGHUP)
+
+	for {
+		select {
+		case s := <-w.nowPlaying:
+			if m := conf.instance(s.Instance); m != nil && !m.Duration {
+				s.Duration = 0
 			}
-		}
-	}()
+			svcs.dispatcher.NowPlaying(songTrack(s))
 
-	catchInterrupt()
+		case s := <-w.toSubmit:
+			svcs.dispatcher.Scrobble(songTrack(s))
 
-	close(quitchan)
-	wg.Wait()
+		case l := <-w.loves:
+			svcs.dispatcher.Love(songTrack(l.Song), l.Love)
+
+		case sig := <-signals:
+			if sig == syscall.SIGHUP {
+				log.Printf("caught %s: reloading config", sig)
+				conf = reload(conf, w, svcs)
+				continue
+			}
+
+			log.Printf("caught %s: shutting down", sig)
+			return
+		}
+	}
 }
@@ -0,0 +1,244 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/softashell/mpd-scrobbler/scrobble"
+)
+
+var errQueueUsage = errors.New(`usage: queue list [<service>]
+       queue show <queue>/<id>
+       queue delete <queue>/<id>...
+       queue retry [<service>]
+       queue purge -older-than <age> [<service>]`)
+
+func queueCommand(db scrobble.Database, conf *Config, args []string) error {
+	if len(args) == 0 {
+		return errQueueUsage
+	}
+
+	switch cmd, args := args[0], args[1:]; cmd {
+	case "list":
+		return queueList(db, conf, args)
+	case "show":
+		return queueShow(db, args)
+	case "delete":
+		return queueDelete(db, args)
+	case "retry":
+		return queueRetry(db, conf, args)
+	case "purge":
+		return queuePurge(db, conf, args)
+	default:
+		return errQueueUsage
+	}
+}
+
+// queueServices returns the services named in args, or all of them.
+func queueServices(conf *Config, args []string) ([]string, error) {
+	if len(args) > 1 {
+		return nil, errQueueUsage
+	}
+	if len(args) == 0 {
+		return conf.serviceNames(), nil
+	}
+	if _, ok := conf.Services[args[0]]; !ok {
+		return nil, fmt.Errorf("unknown service %q", args[0])
+	}
+	return args, nil
+}
+
+// parseQueueID splits ids as printed by queue list, e.g. "lastfm.dead/12".
+func parseQueueID(s string) (queue string, id uint64, err error) {
+	i := strings.LastIndexByte(s, '/')
+	if i <= 0 {
+		return "", 0, fmt.Errorf("invalid id %q, expected <queue>/<id>", s)
+	}
+	id, err = strconv.ParseUint(s[i+1:], 10, 64)
+	if err != nil {
+		return "", 0, fmt.Errorf("invalid id %q, expected <queue>/<id>", s)
+	}
+	return s[:i], id, nil
+}
+
+func queueList(db scrobble.Database, conf *Config, args []string) error {
+	services, err := queueServices(conf, args)
+	if err != nil {
+		return err
+	}
+
+	for _, service := range services {
+		for _, name := range scrobble.QueueNames(service) {
+			// printed only once the database is released, as stdout may
+			// block while the daemon waits to write
+			_, entries, err := queueEntries(db, name, func(scrobble.Track) bool { return true })
+			if err != nil {
+				return err
+			}
+			for _, e := range entries {
+				fmt.Printf("%s/%d\t%s\t%s\n", name, e.id, e.track.Timestamp.Local().Format("2006-01-02 15:04"), e.track)
+			}
+		}
+	}
+	return nil
+}
+
+func queueShow(db scrobble.Database, args []string) error {
+	if len(args) != 1 {
+		return errQueueUsage
+	}
+	name, id, err := parseQueueID(args[0])
+	if err != nil {
+		return err
+	}
+
+	q, err := db.Queue([]byte(name))
+	if err != nil {
+		return err
+	}
+
+	var track *scrobble.Track
+	err = q.Each(func(i uint64, t scrobble.Track) error {
+		if i == id {
+			track = &t
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if track == nil {
+		return fmt.Errorf("%s: %v", args[0], scrobble.QUEUE_NOT_FOUND)
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(track)
+}
+
+func queueDelete(db scrobble.Database, args []string) error {
+	if len(args) == 0 {
+		return errQueueUsage
+	}
+
+	for _, arg := range args {
+		name, id, err := parseQueueID(arg)
+		if err != nil {
+			return err
+		}
+		q, err := db.Queue([]byte(name))
+		if err != nil {
+			return err
+		}
+		if err := q.Delete(id); err != nil {
+			return fmt.Errorf("%s: %v", arg, err)
+		}
+		fmt.Printf("Deleted %s\n", arg)
+	}
+	return nil
+}
+
+type queueEntry struct {
+	id    uint64
+	track scrobble.Track
+}
+
+// queueEntries returns the entries of the named queue that match keep.
+func queueEntries(db scrobble.Database, name string, keep func(scrobble.Track) bool) (scrobble.Queue, []queueEntry, error) {
+	q, err := db.Queue([]byte(name))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var entries []queueEntry
+	err = q.Each(func(id uint64, track scrobble.Track) error {
+		if keep(track) {
+			entries = append(entries, queueEntry{id, track})
+		}
+		return nil
+	})
+	return q, entries, err
+}
+
+// entryIDs returns the ids of entries, to change them in one transaction.
+func entryIDs(entries []queueEntry) []uint64 {
+	ids := make([]uint64, len(entries))
+	for i, e := range entries {
+		ids[i] = e.id
+	}
+	return ids
+}
+
+// queueRetry moves rejected tracks back into the queue of their service,
+// the daemon submits them with the next scrobble.
+func queueRetry(db scrobble.Database, conf *Config, args []string) error {
+	services, err := queueServices(conf, args)
+	if err != nil {
+		return err
+	}
+
+	for _, service := range services {
+		queue, err := db.Queue([]byte(service))
+		if err != nil {
+			return err
+		}
+		dead, entries, err := queueEntries(db, service+".dead", func(scrobble.Track) bool { return true })
+		if err != nil {
+			return err
+		}
+
+		if len(entries) > 0 {
+			if err := dead.Move(queue, entryIDs(entries)...); err != nil {
+				return err
+			}
+			fmt.Printf("Requeued %d tracks for %s\n", len(entries), service)
+		}
+	}
+	return nil
+}
+
+func queuePurge(db scrobble.Database, conf *Config, args []string) error {
+	fs := flag.NewFlagSet("queue purge", flag.ContinueOnError)
+	olderThan := fs.String("older-than", "", "delete tracks played longer ago than this, e.g. 14d or 36h")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *olderThan == "" {
+		return errQueueUsage
+	}
+	age, err := scrobble.ParseAge(*olderThan)
+	if err != nil {
+		return err
+	}
+
+	services, err := queueServices(conf, fs.Args())
+	if err != nil {
+		return err
+	}
+
+	cutoff := time.Now().Add(-age)
+	for _, service := range services {
+		for _, name := range scrobble.QueueNames(service) {
+			q, entries, err := queueEntries(db, name, func(track scrobble.Track) bool {
+				return track.Timestamp.Before(cutoff)
+			})
+			if err != nil {
+				return err
+			}
+
+			if len(entries) > 0 {
+				if err := q.Delete(entryIDs(entries)...); err != nil {
+					return err
+				}
+				fmt.Printf("Deleted %d tracks from %s\n", len(entries), name)
+			}
+		}
+	}
+	return nil
+}
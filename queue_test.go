@@ -0,0 +1,21 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseQueueID(t *testing.T) {
+	assert := assert.New(t)
+
+	name, id, err := parseQueueID("lastfm.dead/12")
+	assert.Nil(err)
+	assert.Equal("lastfm.dead", name)
+	assert.Equal(uint64(12), id)
+
+	for _, s := range []string{"lastfm", "/12", "lastfm/x", "lastfm/"} {
+		_, _, err := parseQueueID(s)
+		assert.NotNil(err, s)
+	}
+}
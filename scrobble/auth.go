@@ -0,0 +1,50 @@
+package scrobble
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/softashell/mpd-scrobbler/scrobble/lastfm"
+)
+
+const (
+	authPollInterval = 5 * time.Second
+	// request tokens are valid for 60 minutes
+	authTimeout = 60 * time.Minute
+)
+
+// Authorize runs the web authentication flow for the named service and
+// stores the resulting session key in db, so its password is no longer
+// needed. approve is called with the URL the user has to visit to grant
+// access.
+func Authorize(db Database, name string, conf Config, approve func(url string)) error {
+	if conf.Type != "" && conf.Type != TypeLastfm {
+		return fmt.Errorf("%s services don't need authorization", conf.Type)
+	}
+
+	api := lastfm.New(conf.Key, conf.Secret, conf.URI)
+
+	token, err := api.GetToken()
+	if err != nil {
+		return err
+	}
+
+	approve(api.AuthUrl(conf.AuthURI, token))
+
+	deadline := time.Now().Add(authTimeout)
+	for {
+		err = api.GetSession(token)
+		if e, ok := err.(*lastfm.Err); !ok || e.Code != lastfm.ErrUnauthorizedToken {
+			break
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("timed out waiting for authorization")
+		}
+		time.Sleep(authPollInterval)
+	}
+	if err != nil {
+		return err
+	}
+
+	return db.SetSession(name, api.SessionKey())
+}
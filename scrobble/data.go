@@ -5,44 +5,194 @@ import (
 	"errors"
 	"fmt"
 	"strconv"
+	"sync"
 	"time"
 
 	"go.etcd.io/bbolt"
 )
 
 var QUEUE_EMPTY = errors.New("Queue empty")
+var QUEUE_NOT_FOUND = errors.New("Not in queue")
 
 type Track struct {
-	Title       string
-	Artist      string
-	Album       string
-	AlbumArtist string
-	TrackNumber int32
-	Duration    uint32
-	Timestamp   time.Time
+	Title        string
+	Artist       string
+	Album        string
+	AlbumArtist  string
+	TrackNumber  int32
+	DiscNumber   int32 // 0 or -1 if unknown
+	Date         string
+	Duration     uint32
+	Timestamp    time.Time
+	Instance     string   // mpd instance that played it
+	Artists      []string // all artist tags, Artist is the first one
+	AlbumArtists []string
+	Genres       []string
+
+	// MusicBrainz identifiers
+	RecordingID    string
+	TrackID        string
+	ReleaseID      string
+	ReleaseGroupID string
+	ArtistIDs      []string
+}
+
+func (t Track) String() string {
+	if t.Instance == "" {
+		return fmt.Sprintf("%s by %s", t.Title, t.Artist)
+	}
+	return fmt.Sprintf("%s by %s (on %s)", t.Title, t.Artist, t.Instance)
 }
 
 type Database interface {
 	Queue(name []byte) (Queue, error)
+	// Session returns the stored session key of the named service, or an
+	// empty string if there is none.
+	Session(name string) (string, error)
+	SetSession(name, key string) error
+	// State returns the saved playback state of the named mpd client, or nil
+	// if there is none.
+	State(name string) ([]byte, error)
+	SetState(name string, state []byte) error
+	// Record adds the outcome of submitting each of tracks to the named
+	// service, results[i] being that of tracks[i], to their history entries.
+	Record(service string, tracks []Track, results []HistoryResult) error
+	// History returns the entries of tracks played from from until before
+	// to, oldest first.
+	History(from, to time.Time) ([]HistoryEntry, error)
 	Close() error
 }
 
 type Queue interface {
-	Enqueue(Track) error
+	// Enqueue adds tracks to the tail of the queue, all or none of them.
+	Enqueue(tracks ...Track) error
 	Dequeue() (Track, error)
+	DequeueN(n int) ([]Track, error)
+	Len() (int, error)
+	// Peek returns up to n tracks from the head of the queue without
+	// removing them.
+	Peek(n int) ([]Track, error)
+	// Each calls fn for every track in the queue with its id, stopping at
+	// the first error. The database stays locked until it returns, so fn
+	// must be quick and must not use the database.
+	Each(fn func(id uint64, track Track) error) error
+	// Delete removes the tracks with the given ids, it returns
+	// QUEUE_NOT_FOUND and removes none of them if one isn't there.
+	Delete(ids ...uint64) error
+	// Move moves the tracks with the given ids to the tail of to, which has
+	// to be in the same database, in one transaction. Like Delete it moves
+	// none of them if one isn't there.
+	Move(to Queue, ids ...uint64) error
 }
 
+// database opens the bbolt file only for the duration of a transaction, so
+// other processes, like the queue subcommands, can use it while the daemon
+// is running.
 type database struct {
-	*bolt.DB
+	path string
+	lock sync.Mutex // bbolt's file lock doesn't serialize within a process
 }
 
+// lockTimeout is how long a transaction waits for another process to release
+// the database.
+const lockTimeout = 10 * time.Second
+
+// sessionsBucket holds session keys by service name. Names of internal
+// buckets start with a dot and service names may not contain one, so they
+// never clash with the queues of a service.
+var sessionsBucket = []byte(".sessions")
+
+// stateBucket holds the playback state of mpd clients by client name.
+var stateBucket = []byte(".state")
+
 func Open(path string) (Database, error) {
-	db, err := bolt.Open(path, 0600, nil)
+	db := &database{path: path}
+
+	err := db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{sessionsBucket, stateBucket, historyBucket} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
 	if err != nil {
 		return nil, err
 	}
 
-	return &database{db}, nil
+	return db, nil
+}
+
+// open opens the file, read-only ones share the lock with other readers.
+func (this *database) open(readOnly bool) (*bolt.DB, error) {
+	db, err := bolt.Open(this.path, 0600, &bolt.Options{Timeout: lockTimeout, ReadOnly: readOnly})
+	if err == bolt.ErrTimeout {
+		return nil, fmt.Errorf("%s is locked by another process", this.path)
+	}
+	return db, err
+}
+
+func (this *database) View(fn func(*bolt.Tx) error) error {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	db, err := this.open(true)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	return db.View(fn)
+}
+
+func (this *database) Update(fn func(*bolt.Tx) error) error {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	db, err := this.open(false)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	return db.Update(fn)
+}
+
+func (this *database) Close() error {
+	return nil
+}
+
+func (this *database) Session(name string) (key string, err error) {
+	err = this.View(func(tx *bolt.Tx) error {
+		key = string(tx.Bucket(sessionsBucket).Get([]byte(name)))
+		return nil
+	})
+	return
+}
+
+func (this *database) SetSession(name, key string) error {
+	return this.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(sessionsBucket)
+		if key == "" {
+			return b.Delete([]byte(name))
+		}
+		return b.Put([]byte(name), []byte(key))
+	})
+}
+
+func (this *database) State(name string) (state []byte, err error) {
+	err = this.View(func(tx *bolt.Tx) error {
+		// only valid during the transaction
+		if val := tx.Bucket(stateBucket).Get([]byte(name)); val != nil {
+			state = append([]byte(nil), val...)
+		}
+		return nil
+	})
+	return
+}
+
+func (this *database) SetState(name string, state []byte) error {
+	return this.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(stateBucket).Put([]byte(name), state)
+	})
 }
 
 func (this *database) Queue(name []byte) (Queue, error) {
@@ -55,27 +205,32 @@ func (this *database) Queue(name []byte) (Queue, error) {
 		return nil, fmt.Errorf("queue: %s", err)
 	}
 
-	return &queue{this.DB, name}, nil
+	return &queue{this, name}, nil
 }
 
 type queue struct {
-	*bolt.DB
+	*database
 	name []byte
 }
 
-func (this *queue) Enqueue(track Track) error {
+func (this *queue) Enqueue(tracks ...Track) error {
 	return this.Update(func(tx *bolt.Tx) error {
 		b := tx.Bucket(this.name)
-		n, err := b.NextSequence()
-		if err != nil {
-			return err
-		}
-		key := strconv.FormatUint(n, 10)
-		val, err := json.Marshal(track)
-		if err != nil {
-			return err
+		for _, track := range tracks {
+			n, err := b.NextSequence()
+			if err != nil {
+				return err
+			}
+			key := strconv.FormatUint(n, 10)
+			val, err := json.Marshal(track)
+			if err != nil {
+				return err
+			}
+			if err := b.Put([]byte(key), val); err != nil {
+				return err
+			}
 		}
-		return b.Put([]byte(key), val)
+		return nil
 	})
 }
 
@@ -103,3 +258,126 @@ func (this *queue) Dequeue() (track Track, err error) {
 	}
 	return
 }
+
+// DequeueN removes and returns up to n tracks from the head of the queue.
+func (this *queue) DequeueN(n int) (tracks []Track, err error) {
+	err = this.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(this.name)
+		c := b.Cursor()
+
+		var keys [][]byte
+		for key, val := c.First(); key != nil && len(keys) < n; key, val = c.Next() {
+			var track Track
+			if err := json.Unmarshal(val, &track); err != nil {
+				return err
+			}
+			tracks = append(tracks, track)
+			keys = append(keys, key)
+		}
+
+		for _, key := range keys {
+			if err := b.Delete(key); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+
+	if err == nil && len(tracks) == 0 {
+		err = QUEUE_EMPTY
+	}
+	return
+}
+
+func (this *queue) Len() (n int, err error) {
+	err = this.View(func(tx *bolt.Tx) error {
+		n = tx.Bucket(this.name).Stats().KeyN
+		return nil
+	})
+	return
+}
+
+func (this *queue) Peek(n int) (tracks []Track, err error) {
+	err = this.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(this.name).Cursor()
+		for key, val := c.First(); key != nil && len(tracks) < n; key, val = c.Next() {
+			var track Track
+			if err := json.Unmarshal(val, &track); err != nil {
+				return err
+			}
+			tracks = append(tracks, track)
+		}
+		return nil
+	})
+	return
+}
+
+func (this *queue) Each(fn func(id uint64, track Track) error) error {
+	return this.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(this.name).ForEach(func(key, val []byte) error {
+			id, err := strconv.ParseUint(string(key), 10, 64)
+			if err != nil {
+				return err
+			}
+			var track Track
+			if err := json.Unmarshal(val, &track); err != nil {
+				return err
+			}
+			return fn(id, track)
+		})
+	})
+}
+
+func (this *queue) Delete(ids ...uint64) error {
+	return this.Update(func(tx *bolt.Tx) error {
+		_, err := this.remove(tx, ids)
+		return err
+	})
+}
+
+func (this *queue) Move(to Queue, ids ...uint64) error {
+	dest, ok := to.(*queue)
+	if !ok || dest.database != this.database {
+		return fmt.Errorf("can't move tracks from %s to a queue of another database", this.name)
+	}
+
+	return this.Update(func(tx *bolt.Tx) error {
+		vals, err := this.remove(tx, ids)
+		if err != nil {
+			return err
+		}
+
+		b := tx.Bucket(dest.name)
+		for _, val := range vals {
+			n, err := b.NextSequence()
+			if err != nil {
+				return err
+			}
+			if err := b.Put([]byte(strconv.FormatUint(n, 10)), val); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// remove deletes the tracks with the given ids within tx and returns them
+// encoded.
+func (this *queue) remove(tx *bolt.Tx, ids []uint64) ([][]byte, error) {
+	b := tx.Bucket(this.name)
+
+	var vals [][]byte
+	for _, id := range ids {
+		key := []byte(strconv.FormatUint(id, 10))
+		val := b.Get(key)
+		if val == nil {
+			return nil, QUEUE_NOT_FOUND
+		}
+		// only valid until the key is deleted
+		vals = append(vals, append([]byte(nil), val...))
+		if err := b.Delete(key); err != nil {
+			return nil, err
+		}
+	}
+	return vals, nil
+}
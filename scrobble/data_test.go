@@ -14,16 +14,15 @@ func TestQueue(t *testing.T) {
 	db, _ := Open(path)
 	defer os.Remove(path)
 
-	t1 := Track{"Track 01", "John", "Cool", "John", 1, 0, time.Now().UTC()}
-	t2 := Track{"Another1", "John", "Cool", "John", 1, 0, time.Now().UTC()}
-	t3 := Track{"What2", "Dave", "What", "YesDave", 1, 0, time.Now().UTC()}
+	t1 := Track{Title: "Track 01", Artist: "John", Album: "Cool", AlbumArtist: "John", TrackNumber: 1, Timestamp: time.Now().UTC()}
+	t2 := Track{Title: "Another1", Artist: "John", Album: "Cool", AlbumArtist: "John", TrackNumber: 1, Timestamp: time.Now().UTC()}
+	t3 := Track{Title: "What2", Artist: "Dave", Album: "What", AlbumArtist: "YesDave", TrackNumber: 1, Timestamp: time.Now().UTC()}
 
 	assert := assert.New(t)
 
 	q, _ := db.Queue([]byte("cool"))
 	assert.Nil(q.Enqueue(t1))
-	assert.Nil(q.Enqueue(t2))
-	assert.Nil(q.Enqueue(t3))
+	assert.Nil(q.Enqueue(t2, t3))
 
 	r1, err1 := q.Dequeue()
 	r2, err2 := q.Dequeue()
@@ -39,3 +38,186 @@ func TestQueue(t *testing.T) {
 	assert.Equal(t3, r3)
 	assert.Equal(err, QUEUE_EMPTY)
 }
+
+func TestDequeueN(t *testing.T) {
+	path := filepath.Join(os.TempDir(), "data_test_n")
+	db, _ := Open(path)
+	defer os.Remove(path)
+
+	assert := assert.New(t)
+
+	q, _ := db.Queue([]byte("batch"))
+	var tracks []Track
+	for i := 0; i < 5; i++ {
+		track := Track{Title: "Track", Artist: "John", Album: "Cool", AlbumArtist: "John", TrackNumber: int32(i), Timestamp: time.Now().UTC()}
+		tracks = append(tracks, track)
+		assert.Nil(q.Enqueue(track))
+	}
+
+	r1, err1 := q.DequeueN(3)
+	r2, err2 := q.DequeueN(3)
+	_, err := q.DequeueN(3)
+
+	assert.Nil(err1)
+	assert.Nil(err2)
+
+	assert.Equal(tracks[:3], r1)
+	assert.Equal(tracks[3:], r2)
+	assert.Equal(err, QUEUE_EMPTY)
+}
+
+func TestSession(t *testing.T) {
+	path := filepath.Join(os.TempDir(), "data_test_session")
+	db, _ := Open(path)
+	defer os.Remove(path)
+
+	assert := assert.New(t)
+
+	key, err := db.Session("lastfm")
+	assert.Nil(err)
+	assert.Equal("", key)
+
+	assert.Nil(db.SetSession("lastfm", "secret"))
+	db.Close()
+
+	db, _ = Open(path)
+	defer db.Close()
+
+	key, err = db.Session("lastfm")
+	assert.Nil(err)
+	assert.Equal("secret", key)
+
+	assert.Nil(db.SetSession("lastfm", ""))
+	key, _ = db.Session("lastfm")
+	assert.Equal("", key)
+}
+
+func TestState(t *testing.T) {
+	path := filepath.Join(os.TempDir(), "data_test_state")
+	db, _ := Open(path)
+	defer os.Remove(path)
+	defer db.Close()
+
+	assert := assert.New(t)
+
+	state, err := db.State("mpd")
+	assert.Nil(err)
+	assert.Nil(state)
+
+	assert.Nil(db.SetState("mpd", []byte(`{"Start":12}`)))
+	state, err = db.State("mpd")
+	assert.Nil(err)
+	assert.Equal([]byte(`{"Start":12}`), state)
+}
+
+func TestHistory(t *testing.T) {
+	path := filepath.Join(os.TempDir(), "data_test_history")
+	db, _ := Open(path)
+	defer os.Remove(path)
+	defer db.Close()
+
+	assert := assert.New(t)
+
+	start := time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)
+	t1 := Track{Title: "Track 01", Artist: "John", Timestamp: start}
+	t2 := Track{Title: "What2", Artist: "Dave", Timestamp: start.Add(time.Hour), Instance: "office"}
+	t3 := Track{Title: "Later", Artist: "Dave", Timestamp: start.Add(2 * time.Hour)}
+
+	accepted := HistoryResult{Status: StatusAccepted, Submitted: start}
+	rejected := HistoryResult{Status: StatusRejected, Message: "invalid", Submitted: start}
+
+	assert.Nil(db.Record("lastfm", []Track{t2, t1}, []HistoryResult{accepted, accepted}))
+	assert.Nil(db.Record("listenbrainz", []Track{t1}, []HistoryResult{rejected}))
+	assert.Nil(db.Record("lastfm", []Track{t3}, []HistoryResult{accepted}))
+
+	entries, err := db.History(start, t3.Timestamp)
+	assert.Nil(err)
+	assert.Equal([]HistoryEntry{
+		{t1, map[string]HistoryResult{"lastfm": accepted, "listenbrainz": rejected}},
+		{t2, map[string]HistoryResult{"lastfm": accepted}},
+	}, entries)
+
+	entries, _ = db.History(start.Add(time.Minute), start.Add(24*time.Hour))
+	assert.Len(entries, 2)
+
+	// scrobbler.log imports can start two tracks in the same second
+	t4 := Track{Title: "Skipped", Artist: "John", Timestamp: start}
+	assert.Nil(db.Record("lastfm", []Track{t4}, []HistoryResult{rejected}))
+	entries, _ = db.History(start, start.Add(time.Second))
+	assert.Equal([]HistoryEntry{
+		{t4, map[string]HistoryResult{"lastfm": rejected}},
+		{t1, map[string]HistoryResult{"lastfm": accepted, "listenbrainz": rejected}},
+	}, entries)
+}
+
+func TestQueueInspect(t *testing.T) {
+	path := filepath.Join(os.TempDir(), "data_test_inspect")
+	db, _ := Open(path)
+	defer os.Remove(path)
+
+	// a second process, e.g. the queue subcommands, sees the same data
+	other, err := Open(path)
+	assert := assert.New(t)
+	assert.Nil(err)
+
+	q, _ := db.Queue([]byte("inspect"))
+	var tracks []Track
+	for i := 0; i < 3; i++ {
+		track := Track{Title: "Track", Artist: "John", TrackNumber: int32(i), Timestamp: time.Now().UTC()}
+		tracks = append(tracks, track)
+		assert.Nil(q.Enqueue(track))
+	}
+
+	oq, _ := other.Queue([]byte("inspect"))
+	n, err := oq.Len()
+	assert.Nil(err)
+	assert.Equal(3, n)
+
+	peeked, err := oq.Peek(2)
+	assert.Nil(err)
+	assert.Equal(tracks[:2], peeked)
+
+	var ids []uint64
+	assert.Nil(oq.Each(func(id uint64, track Track) error {
+		ids = append(ids, id)
+		return nil
+	}))
+	assert.Equal([]uint64{1, 2, 3}, ids)
+
+	assert.Nil(oq.Delete(2))
+	assert.Equal(QUEUE_NOT_FOUND, oq.Delete(2))
+
+	rest, _ := q.DequeueN(3)
+	assert.Equal([]Track{tracks[0], tracks[2]}, rest)
+}
+
+func TestQueueMove(t *testing.T) {
+	path := filepath.Join(os.TempDir(), "data_test_move")
+	db, _ := Open(path)
+	defer os.Remove(path)
+	defer db.Close()
+
+	assert := assert.New(t)
+
+	dead, _ := db.Queue([]byte("move.dead"))
+	q, _ := db.Queue([]byte("move"))
+	t1 := Track{Title: "Track 01", Artist: "John", Timestamp: time.Now().UTC()}
+	t2 := Track{Title: "What2", Artist: "Dave", Timestamp: time.Now().UTC()}
+	t3 := Track{Title: "Later", Artist: "Dave", Timestamp: time.Now().UTC()}
+	assert.Nil(dead.Enqueue(t1, t2, t3))
+	assert.Nil(q.Enqueue(t2))
+
+	// nothing moves if one of them is gone
+	assert.Equal(QUEUE_NOT_FOUND, dead.Move(q, 1, 4))
+	n, _ := dead.Len()
+	assert.Equal(3, n)
+
+	assert.Nil(dead.Move(q, 1, 3))
+	tracks, _ := q.DequeueN(3)
+	assert.Equal([]Track{t2, t1, t3}, tracks)
+
+	assert.Equal(QUEUE_NOT_FOUND, dead.Delete(2, 3))
+	assert.Nil(dead.Delete(2))
+	_, err := dead.Dequeue()
+	assert.Equal(QUEUE_EMPTY, err)
+}
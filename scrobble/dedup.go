@@ -0,0 +1,92 @@
+package scrobble
+
+import (
+	"log"
+	"time"
+)
+
+// DefaultDedupWindow is how close together two submissions of the same track
+// have to start to count as one listen, unless set with dedup_window.
+const DefaultDedupWindow = 5 * time.Minute
+
+// DuplicateWindow returns the dedup window of the service, or 0 if
+// duplicates are submitted.
+func (conf Config) DuplicateWindow() (time.Duration, error) {
+	if conf.DedupWindow == "" {
+		return DefaultDedupWindow, nil
+	}
+	return ParseAge(conf.DedupWindow)
+}
+
+// dropDuplicates returns tracks without the ones that were already submitted
+// to the service, going by the history. Reconnecting to mpd can make the
+// client see the same listen twice, a few seconds apart.
+func (api *queuedScrobbler) dropDuplicates(tracks []Track) []Track {
+	if api.dedupWindow == 0 || len(tracks) == 0 {
+		return tracks
+	}
+
+	// one lookup for the whole batch, which is played in order anyway
+	from, to := tracks[0].Timestamp, tracks[0].Timestamp
+	for _, track := range tracks[1:] {
+		if track.Timestamp.Before(from) {
+			from = track.Timestamp
+		}
+		if track.Timestamp.After(to) {
+			to = track.Timestamp
+		}
+	}
+	history, err := api.db.History(from.Add(-api.dedupWindow), to.Add(api.dedupWindow))
+	if err != nil {
+		log.Printf("[%s] History error: %v\n", api.Name(), err)
+	}
+
+	var keep []Track
+	for _, track := range tracks {
+		if api.isDuplicate(track, history, keep) {
+			log.Printf("[%s] Duplicate, not submitting: %s\n", api.Name(), track)
+			continue
+		}
+		keep = append(keep, track)
+	}
+	return keep
+}
+
+// isDuplicate tells if track is in history as submitted or is in pending.
+// The window is shortened to the length of the track, so a track on repeat
+// is still scrobbled every time.
+func (api *queuedScrobbler) isDuplicate(track Track, history []HistoryEntry, pending []Track) bool {
+	window := api.dedupWindow
+	if d := time.Duration(track.Duration) * time.Second; d > 0 && d < window {
+		window = d
+	}
+
+	for _, p := range pending {
+		if sameListen(p, track, window) {
+			return true
+		}
+	}
+
+	for _, entry := range history {
+		result, ok := entry.Results[api.Name()]
+		if !ok || (result.Status != StatusAccepted && result.Status != StatusIgnored) {
+			continue
+		}
+		if sameListen(entry.Track, track, window) {
+			return true
+		}
+	}
+	return false
+}
+
+func sameListen(a, b Track, window time.Duration) bool {
+	d := a.Timestamp.Sub(b.Timestamp)
+	if d < 0 {
+		d = -d
+	}
+	return d < window &&
+		a.Instance == b.Instance &&
+		a.Artist == b.Artist &&
+		a.Title == b.Title &&
+		a.Album == b.Album
+}
@@ -0,0 +1,205 @@
+package scrobble
+
+import (
+	"log"
+	"sync"
+)
+
+// dispatchBuffer is the number of pending requests kept per service.
+const dispatchBuffer = 32
+
+// Dispatcher hands tracks to every scrobbler from a separate worker, so a
+// slow or hung service never delays the others or the caller.
+type Dispatcher struct {
+	lock    sync.Mutex
+	workers map[string]*worker
+}
+
+type jobKind int
+
+const (
+	jobScrobble jobKind = iota
+	jobNowPlaying
+	jobLove
+	jobUnlove
+)
+
+type job struct {
+	track Track
+	kind  jobKind
+}
+
+type worker struct {
+	api  Scrobbler
+	jobs chan job
+	quit chan struct{}
+	done chan struct{}
+}
+
+// queuer is implemented by scrobblers that can hold tracks for later.
+type queuer interface {
+	enqueue(tracks []Track)
+	enqueueLove(track Track, love bool)
+}
+
+func NewDispatcher(apis []Scrobbler) *Dispatcher {
+	d := &Dispatcher{workers: map[string]*worker{}}
+	for _, api := range apis {
+		d.Add(api)
+	}
+	return d
+}
+
+// Add starts dispatching to api. A service of the same name is removed
+// first.
+func (d *Dispatcher) Add(api Scrobbler) {
+	d.Remove(api.Name())
+
+	w := &worker{
+		api:  api,
+		jobs: make(chan job, dispatchBuffer),
+		quit: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+	go w.run()
+
+	d.lock.Lock()
+	d.workers[api.Name()] = w
+	d.lock.Unlock()
+}
+
+// Remove stops dispatching to the named service. It waits for a request in
+// flight to finish and queues the pending scrobbles.
+func (d *Dispatcher) Remove(name string) {
+	d.lock.Lock()
+	w, ok := d.workers[name]
+	delete(d.workers, name)
+	d.lock.Unlock()
+
+	if ok {
+		w.close()
+	}
+}
+
+// NowPlaying sends track as now playing to every service. Services that are
+// too far behind skip it.
+func (d *Dispatcher) NowPlaying(track Track) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	for _, w := range d.workers {
+		select {
+		case w.jobs <- job{track, jobNowPlaying}:
+		default:
+			log.Printf("[%s] Busy, skipping NowPlaying: %s\n", w.api.Name(), track)
+		}
+	}
+}
+
+// Scrobble submits track to every service. Services that are too far behind
+// get it queued in the database instead.
+func (d *Dispatcher) Scrobble(track Track) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	for _, w := range d.workers {
+		select {
+		case w.jobs <- job{track, jobScrobble}:
+		default:
+			w.enqueue(track)
+		}
+	}
+}
+
+// Love loves or unloves track on every service. Services that are too far
+// behind get it queued in the database instead.
+func (d *Dispatcher) Love(track Track, love bool) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	kind := jobLove
+	if !love {
+		kind = jobUnlove
+	}
+	for _, w := range d.workers {
+		select {
+		case w.jobs <- job{track, kind}:
+		default:
+			w.enqueueLove(track, love)
+		}
+	}
+}
+
+// Close waits for requests in flight to finish and queues the pending
+// scrobbles.
+func (d *Dispatcher) Close() {
+	d.lock.Lock()
+	workers := d.workers
+	d.workers = map[string]*worker{}
+	d.lock.Unlock()
+
+	var wg sync.WaitGroup
+	for _, w := range workers {
+		wg.Add(1)
+		go func(w *worker) {
+			defer wg.Done()
+			w.close()
+		}(w)
+	}
+	wg.Wait()
+}
+
+func (w *worker) run() {
+	defer close(w.done)
+
+	for j := range w.jobs {
+		select {
+		case <-w.quit:
+			switch j.kind {
+			case jobScrobble:
+				w.enqueue(j.track)
+			case jobLove, jobUnlove:
+				w.enqueueLove(j.track, j.kind == jobLove)
+			}
+			continue
+		default:
+		}
+
+		switch j.kind {
+		case jobNowPlaying:
+			if _, err := w.api.NowPlaying(j.track); err != nil {
+				log.Printf("[%s] err(NowPlaying): %s\n", w.api.Name(), err)
+			}
+		case jobScrobble:
+			if _, err := w.api.Scrobble(j.track); err != nil {
+				log.Printf("[%s] err(Scrobble): %s\n", w.api.Name(), err)
+			}
+		case jobLove, jobUnlove:
+			if err := w.api.Love(j.track, j.kind == jobLove); err != nil {
+				log.Printf("[%s] err(Love): %s\n", w.api.Name(), err)
+			}
+		}
+	}
+}
+
+func (w *worker) enqueue(track Track) {
+	if q, ok := w.api.(queuer); ok {
+		q.enqueue([]Track{track})
+	} else {
+		log.Printf("[%s] Busy, dropping: %s\n", w.api.Name(), track)
+	}
+}
+
+func (w *worker) enqueueLove(track Track, love bool) {
+	if q, ok := w.api.(queuer); ok {
+		q.enqueueLove(track, love)
+	} else {
+		log.Printf("[%s] Busy, dropping love: %s\n", w.api.Name(), track)
+	}
+}
+
+func (w *worker) close() {
+	close(w.quit)
+	close(w.jobs)
+	<-w.done
+}
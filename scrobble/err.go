@@ -10,3 +10,34 @@ type Err struct {
 func (e *Err) Error() string {
 	return fmt.Sprintf("%v: %v", e.name, e.err.Error())
 }
+
+// errorClass tells what to do with tracks whose submission failed.
+type errorClass int
+
+const (
+	errRetry   errorClass = iota // keep the tracks queued
+	errRelogin                   // renew the session, then retry
+	errDrop                      // move the tracks to the dead-letter queue
+)
+
+func classify(err error) errorClass {
+	if e, ok := err.(interface{ InvalidSession() bool }); ok && e.InvalidSession() {
+		return errRelogin
+	}
+	if e, ok := err.(interface{ Permanent() bool }); ok && e.Permanent() {
+		return errDrop
+	}
+	return errRetry
+}
+
+// rejectedError is returned for requests a service can't take at all, they
+// are dropped like the ones it rejected itself.
+type rejectedError string
+
+func (e rejectedError) Error() string {
+	return string(e)
+}
+
+func (e rejectedError) Permanent() bool {
+	return true
+}
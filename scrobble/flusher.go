@@ -0,0 +1,95 @@
+package scrobble
+
+import (
+	"log"
+	"math/rand"
+	"time"
+)
+
+const (
+	minBackoff       = 30 * time.Second
+	maxBackoff       = 1 * time.Hour
+	rateLimitBackoff = 10 * time.Minute
+)
+
+// flusher submits the queue in the background whenever the service appears
+// to be reachable, backing off exponentially while it is not.
+func (api *queuedScrobbler) flusher() {
+	defer close(api.done)
+
+	var backoff time.Duration
+	var retry <-chan time.Time
+
+	flush := func() {
+		err := api.flush()
+		if err == nil {
+			backoff, retry = 0, nil
+			return
+		}
+		backoff = nextBackoff(backoff, err)
+		delay := jitter(backoff)
+		log.Printf("[%s] Retrying queue in %s\n", api.Name(), delay.Round(time.Second))
+		retry = time.After(delay)
+	}
+
+	flush()
+	for {
+		select {
+		case <-api.quit:
+			return
+
+		case err := <-api.notify:
+			if err == nil {
+				// a submission just went through, so don't wait any longer
+				flush()
+			} else if retry == nil {
+				backoff = nextBackoff(backoff, err)
+				retry = time.After(jitter(backoff))
+			}
+
+		case <-retry:
+			flush()
+		}
+	}
+}
+
+// notifyFlusher tells the flusher about the outcome of a submission without
+// ever blocking the caller.
+func (api *queuedScrobbler) notifyFlusher(err error) {
+	select {
+	case api.notify <- err:
+	default:
+	}
+}
+
+// apiError is implemented by errors returned by the service itself, as
+// opposed to network errors.
+type apiError interface {
+	Temporary() bool
+	Permanent() bool
+}
+
+func nextBackoff(prev time.Duration, err error) time.Duration {
+	next := prev * 2
+	if next < minBackoff {
+		next = minBackoff
+	}
+
+	if e, ok := err.(interface{ RateLimited() bool }); ok && e.RateLimited() && next < rateLimitBackoff {
+		next = rateLimitBackoff
+	} else if e, ok := err.(apiError); ok && !e.Temporary() {
+		// the service is up but refuses us, e.g. a bad api key; this is
+		// unlikely to fix itself soon
+		next = maxBackoff
+	}
+
+	if next > maxBackoff {
+		next = maxBackoff
+	}
+	return next
+}
+
+// jitter spreads retries over the second half of the backoff interval.
+func jitter(d time.Duration) time.Duration {
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+}
@@ -0,0 +1,284 @@
+package scrobble
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Formats tracks can be exported to and imported from.
+const (
+	FormatJSONLines    = "jsonl"
+	FormatCSV          = "csv"
+	FormatScrobblerLog = "scrobbler-log" // Audioscrobbler .scrobbler.log of portable players
+)
+
+// Encoder writes tracks in one of the export formats.
+type Encoder interface {
+	Encode(track Track) error
+	// Flush writes any buffered data, it has to be called once done.
+	Flush() error
+}
+
+// Decoder reads tracks in one of the export formats. Decode returns io.EOF
+// after the last track.
+type Decoder interface {
+	Decode() (Track, error)
+}
+
+func NewEncoder(w io.Writer, format string) (Encoder, error) {
+	switch format {
+	case FormatJSONLines:
+		return &jsonEncoder{bufio.NewWriter(w)}, nil
+	case FormatCSV:
+		return &csvEncoder{w: csv.NewWriter(w)}, nil
+	case FormatScrobblerLog:
+		return &logEncoder{w: bufio.NewWriter(w)}, nil
+	}
+	return nil, fmt.Errorf("unknown format %q", format)
+}
+
+func NewDecoder(r io.Reader, format string) (Decoder, error) {
+	switch format {
+	case FormatJSONLines:
+		return &jsonDecoder{json.NewDecoder(r)}, nil
+	case FormatCSV:
+		c := csv.NewReader(r)
+		c.FieldsPerRecord = -1
+		return &csvDecoder{r: c}, nil
+	case FormatScrobblerLog:
+		return &logDecoder{r: bufio.NewScanner(r), tz: time.UTC}, nil
+	}
+	return nil, fmt.Errorf("unknown format %q", format)
+}
+
+type jsonEncoder struct {
+	w *bufio.Writer
+}
+
+func (e *jsonEncoder) Encode(track Track) error {
+	data, err := json.Marshal(track)
+	if err != nil {
+		return err
+	}
+	e.w.Write(data)
+	return e.w.WriteByte('\n')
+}
+
+func (e *jsonEncoder) Flush() error {
+	return e.w.Flush()
+}
+
+type jsonDecoder struct {
+	d *json.Decoder
+}
+
+func (d *jsonDecoder) Decode() (track Track, err error) {
+	err = d.d.Decode(&track)
+	return
+}
+
+// csvHeader names the columns of the CSV format. Columns are found by name
+// when reading, so they can be in any order and missing ones are left empty.
+var csvHeader = []string{"timestamp", "artist", "title", "album", "album_artist", "track_number", "duration", "recording_mbid", "instance"}
+
+type csvEncoder struct {
+	w      *csv.Writer
+	header bool
+}
+
+func (e *csvEncoder) Encode(track Track) error {
+	if !e.header {
+		e.header = true
+		if err := e.w.Write(csvHeader); err != nil {
+			return err
+		}
+	}
+
+	number := ""
+	if track.TrackNumber > 0 {
+		number = strconv.Itoa(int(track.TrackNumber))
+	}
+	return e.w.Write([]string{
+		track.Timestamp.UTC().Format(time.RFC3339),
+		track.Artist,
+		track.Title,
+		track.Album,
+		track.AlbumArtist,
+		number,
+		strconv.FormatUint(uint64(track.Duration), 10),
+		track.RecordingID,
+		track.Instance,
+	})
+}
+
+func (e *csvEncoder) Flush() error {
+	e.w.Flush()
+	return e.w.Error()
+}
+
+type csvDecoder struct {
+	r       *csv.Reader
+	columns map[string]int
+}
+
+func (d *csvDecoder) Decode() (track Track, err error) {
+	if d.columns == nil {
+		header, err := d.r.Read()
+		if err != nil {
+			return track, err
+		}
+		d.columns = map[string]int{}
+		for i, name := range header {
+			d.columns[strings.TrimSpace(name)] = i
+		}
+		if _, ok := d.columns["timestamp"]; !ok {
+			return track, fmt.Errorf("csv: no timestamp column")
+		}
+	}
+
+	record, err := d.r.Read()
+	if err != nil {
+		return track, err
+	}
+	line, _ := d.r.FieldPos(0)
+	field := func(name string) string {
+		if i, ok := d.columns[name]; ok && i < len(record) {
+			return record[i]
+		}
+		return ""
+	}
+
+	if track.Timestamp, err = time.Parse(time.RFC3339, field("timestamp")); err != nil {
+		return track, fmt.Errorf("csv: line %d: %v", line, err)
+	}
+	track.Artist = field("artist")
+	track.Title = field("title")
+	track.Album = field("album")
+	track.AlbumArtist = field("album_artist")
+	track.TrackNumber = parseTrackNumber(field("track_number"))
+	track.Duration = parseDuration(field("duration"))
+	track.RecordingID = field("recording_mbid")
+	track.Instance = field("instance")
+	return track, nil
+}
+
+// logEncoder writes the Audioscrobbler portable player log, version 1.1.
+type logEncoder struct {
+	w      *bufio.Writer
+	header bool
+}
+
+func (e *logEncoder) Encode(track Track) error {
+	if !e.header {
+		e.header = true
+		e.w.WriteString("#AUDIOSCROBBLER/1.1\n#TZ/UTC\n#CLIENT/mpd-scrobbler\n")
+	}
+
+	number := ""
+	if track.TrackNumber > 0 {
+		number = strconv.Itoa(int(track.TrackNumber))
+	}
+	fields := []string{
+		track.Artist,
+		track.Album,
+		track.Title,
+		number,
+		strconv.FormatUint(uint64(track.Duration), 10),
+		"L", // listened, skipped tracks are never scrobbled
+		strconv.FormatInt(track.Timestamp.Unix(), 10),
+		track.RecordingID,
+	}
+	for i, f := range fields {
+		// tabs and newlines would break the line format
+		fields[i] = strings.Map(func(r rune) rune {
+			if r == '\t' || r == '\n' || r == '\r' {
+				return ' '
+			}
+			return r
+		}, f)
+	}
+	_, err := e.w.WriteString(strings.Join(fields, "\t") + "\n")
+	return err
+}
+
+func (e *logEncoder) Flush() error {
+	return e.w.Flush()
+}
+
+type logDecoder struct {
+	r    *bufio.Scanner
+	tz   *time.Location // of the timestamps
+	line int
+}
+
+// Decode skips tracks marked as skipped. Timestamps of logs written with
+// #TZ/UNKNOWN are taken to be local time.
+func (d *logDecoder) Decode() (track Track, err error) {
+	for d.r.Scan() {
+		d.line++
+		text := strings.TrimRight(d.r.Text(), "\r")
+		if text == "" {
+			continue
+		}
+		if strings.HasPrefix(text, "#") {
+			if text == "#TZ/UNKNOWN" {
+				d.tz = time.Local
+			}
+			continue
+		}
+
+		fields := strings.Split(text, "\t")
+		if len(fields) < 7 {
+			return track, fmt.Errorf("scrobbler.log: line %d: expected at least 7 fields", d.line)
+		}
+		if fields[5] == "S" {
+			continue
+		}
+
+		ts, err := strconv.ParseInt(fields[6], 10, 64)
+		if err != nil {
+			return track, fmt.Errorf("scrobbler.log: line %d: invalid timestamp %q", d.line, fields[6])
+		}
+		track.Timestamp = time.Unix(ts, 0).UTC()
+		if d.tz != time.UTC {
+			t := track.Timestamp
+			track.Timestamp = time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), 0, d.tz).UTC()
+		}
+		track.Artist = fields[0]
+		track.Album = fields[1]
+		track.Title = fields[2]
+		track.TrackNumber = parseTrackNumber(fields[3])
+		track.Duration = parseDuration(fields[4])
+		if len(fields) > 7 {
+			track.RecordingID = fields[7]
+		}
+		return track, nil
+	}
+
+	if err := d.r.Err(); err != nil {
+		return track, err
+	}
+	return track, io.EOF
+}
+
+func parseTrackNumber(s string) int32 {
+	n, err := strconv.ParseUint(s, 10, 31)
+	if err != nil {
+		return -1
+	}
+	return int32(n)
+}
+
+func parseDuration(s string) uint32 {
+	n, err := strconv.ParseUint(s, 10, 32)
+	if err != nil {
+		return 0
+	}
+	return uint32(n)
+}
@@ -0,0 +1,74 @@
+package scrobble
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFormatRoundTrip(t *testing.T) {
+	assert := assert.New(t)
+
+	track := Track{
+		Title:       "Track 01",
+		Artist:      "John",
+		Album:       "Cool",
+		AlbumArtist: "John",
+		TrackNumber: 1,
+		Duration:    200,
+		RecordingID: "b1a9c0e9",
+		Timestamp:   time.Unix(1500000000, 0).UTC(),
+	}
+	noNumber := track
+	noNumber.TrackNumber = -1
+
+	for _, format := range []string{FormatJSONLines, FormatCSV, FormatScrobblerLog} {
+		var buf bytes.Buffer
+		enc, err := NewEncoder(&buf, format)
+		assert.Nil(err)
+		assert.Nil(enc.Encode(track))
+		assert.Nil(enc.Encode(noNumber))
+		assert.Nil(enc.Flush())
+
+		want := []Track{track, noNumber}
+		if format == FormatScrobblerLog {
+			// the log has no album artist
+			want[0].AlbumArtist, want[1].AlbumArtist = "", ""
+		}
+
+		dec, err := NewDecoder(&buf, format)
+		assert.Nil(err)
+		for _, w := range want {
+			got, err := dec.Decode()
+			assert.Nil(err, format)
+			assert.Equal(w, got, format)
+		}
+		_, err = dec.Decode()
+		assert.Equal(io.EOF, err, format)
+	}
+}
+
+func TestDecodeScrobblerLog(t *testing.T) {
+	assert := assert.New(t)
+
+	log := "#AUDIOSCROBBLER/1.1\n" +
+		"#TZ/UTC\n" +
+		"#CLIENT/Rockbox h300 $Revision$\n" +
+		"John\tCool\tSkipped\t1\t200\tS\t1500000000\t\n" +
+		"John\tCool\tListened\t\t180\tL\t1500000300\n"
+
+	dec, _ := NewDecoder(strings.NewReader(log), FormatScrobblerLog)
+	track, err := dec.Decode()
+	assert.Nil(err)
+	assert.Equal(Track{Title: "Listened", Artist: "John", Album: "Cool", TrackNumber: -1, Duration: 180, Timestamp: time.Unix(1500000300, 0).UTC()}, track)
+
+	_, err = dec.Decode()
+	assert.Equal(io.EOF, err)
+
+	_, err = NewDecoder(strings.NewReader(log), "xml")
+	assert.NotNil(err)
+}
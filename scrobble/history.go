@@ -0,0 +1,114 @@
+package scrobble
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"time"
+
+	"go.etcd.io/bbolt"
+)
+
+// historyBucket holds a HistoryEntry for every track that reached a service,
+// keyed by historyKey.
+var historyBucket = []byte(".history")
+
+// Outcomes of a submission.
+const (
+	StatusAccepted = "accepted"
+	StatusIgnored  = "ignored"  // accepted by the service but not counted
+	StatusRejected = "rejected" // moved to the dead-letter queue
+	StatusExpired  = "expired"  // too old for the service, moved to the expired queue
+)
+
+// HistoryEntry is a played track and what each service made of it.
+type HistoryEntry struct {
+	Track   Track
+	Results map[string]HistoryResult // by service name
+}
+
+// HistoryResult is the outcome of submitting a track to one service.
+type HistoryResult struct {
+	Status    string
+	Message   string    `json:",omitempty"` // why it was ignored or rejected
+	Corrected *Track    `json:",omitempty"` // as corrected by the service
+	Submitted time.Time // when the service answered
+}
+
+func newHistoryResult(result Result) HistoryResult {
+	r := HistoryResult{Status: StatusAccepted, Submitted: time.Now().UTC()}
+	if result.Ignored() {
+		r.Status = StatusIgnored
+		r.Message = result.IgnoredMessage
+	}
+	if result.Corrected {
+		r.Corrected = &result.Track
+	}
+	return r
+}
+
+// historyKey sorts entries by the time the track started playing. The
+// instance keeps tracks started at the same time on different servers apart,
+// and the artist and title different tracks from the same server, as the
+// second resolution of imported logs makes those collide.
+func historyKey(track Track) []byte {
+	key := timeKey(track.Timestamp)
+	key = append(key, track.Instance...)
+	key = append(key, 0)
+	key = append(key, track.Artist...)
+	key = append(key, 0)
+	return append(key, track.Title...)
+}
+
+// timeKey is the prefix of the keys of tracks started at t.
+func timeKey(t time.Time) []byte {
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, uint64(t.UnixNano()))
+	return key
+}
+
+func (this *database) Record(service string, tracks []Track, results []HistoryResult) error {
+	return this.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(historyBucket)
+		for i, track := range tracks {
+			key := historyKey(track)
+
+			entry := HistoryEntry{Track: track}
+			if val := b.Get(key); val != nil {
+				if err := json.Unmarshal(val, &entry); err != nil {
+					return err
+				}
+			}
+			if entry.Results == nil {
+				entry.Results = map[string]HistoryResult{}
+			}
+			entry.Results[service] = results[i]
+
+			val, err := json.Marshal(entry)
+			if err != nil {
+				return err
+			}
+			if err := b.Put(key, val); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (this *database) History(from, to time.Time) (entries []HistoryEntry, err error) {
+	err = this.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(historyBucket).Cursor()
+		end := timeKey(to)
+
+		for key, val := c.Seek(timeKey(from)); key != nil && bytes.Compare(key, end) < 0; key, val = c.Next() {
+			var entry HistoryEntry
+			if err := json.Unmarshal(val, &entry); err != nil {
+				return err
+			}
+			entries = append(entries, entry)
+		}
+		return nil
+	})
+	return
+}
@@ -14,6 +14,7 @@ type ScrobbleArgs struct {
 	TrackNumber int32
 	Duration    uint32
 	Timestamp   int64
+	Mbid        string // MusicBrainz recording id
 }
 
 func (a ScrobbleArgs) Format() map[string]string {
@@ -32,6 +33,23 @@ func (a ScrobbleArgs) Format() map[string]string {
 	if a.Duration > 0 {
 		m["duration"] = strconv.FormatUint(uint64(a.Duration), 10)
 	}
+	if a.Mbid != "" {
+		m["mbid"] = a.Mbid
+	}
+	return m
+}
+
+// ScrobbleBatch submits several scrobbles in a single track.scrobble call
+// using the array notation (artist[0], track[0], ...).
+type ScrobbleBatch []ScrobbleArgs
+
+func (b ScrobbleBatch) Format() map[string]string {
+	m := map[string]string{}
+	for i, a := range b {
+		for k, v := range a.Format() {
+			m[k+"["+strconv.Itoa(i)+"]"] = v
+		}
+	}
 	return m
 }
 
@@ -42,6 +60,7 @@ type UpdateNowPlayingArgs struct {
 	AlbumArtist string
 	TrackNumber int32
 	Duration    uint32
+	Mbid        string
 }
 
 func (a UpdateNowPlayingArgs) Format() map[string]string {
@@ -59,9 +78,39 @@ func (a UpdateNowPlayingArgs) Format() map[string]string {
 	if a.Duration > 0 {
 		m["duration"] = strconv.FormatUint(uint64(a.Duration), 10)
 	}
+	if a.Mbid != "" {
+		m["mbid"] = a.Mbid
+	}
 	return m
 }
 
+// LoveArgs identifies the track to love or unlove.
+type LoveArgs struct {
+	Artist string
+	Track  string
+}
+
+func (a LoveArgs) Format() map[string]string {
+	return map[string]string{
+		"track":  a.Track,
+		"artist": a.Artist,
+	}
+}
+
+type GetLovedTracksArgs struct {
+	User  string
+	Page  int
+	Limit int
+}
+
+func (a GetLovedTracksArgs) Format() map[string]string {
+	return map[string]string{
+		"user":  a.User,
+		"page":  strconv.Itoa(a.Page),
+		"limit": strconv.Itoa(a.Limit),
+	}
+}
+
 type LoginArgs struct {
 	Username string
 	Password string
@@ -73,3 +122,19 @@ func (a LoginArgs) Format() map[string]string {
 		"password": a.Password,
 	}
 }
+
+type GetSessionArgs struct {
+	Token string
+}
+
+func (a GetSessionArgs) Format() map[string]string {
+	return map[string]string{
+		"token": a.Token,
+	}
+}
+
+type NoArgs struct{}
+
+func (a NoArgs) Format() map[string]string {
+	return map[string]string{}
+}
@@ -0,0 +1,29 @@
+package lastfm
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScrobbleBatchFormat(t *testing.T) {
+	batch := ScrobbleBatch{
+		{Artist: "John", Track: "Track 01", Album: "Cool", TrackNumber: -1, Timestamp: 100},
+		{Artist: "Dave", Track: "What2", Album: "What", AlbumArtist: "YesDave", TrackNumber: 2, Duration: 180, Timestamp: 200, Mbid: "b1a9c0e9"},
+	}
+
+	assert.Equal(t, map[string]string{
+		"artist[0]":      "John",
+		"track[0]":       "Track 01",
+		"album[0]":       "Cool",
+		"timestamp[0]":   "100",
+		"artist[1]":      "Dave",
+		"track[1]":       "What2",
+		"album[1]":       "What",
+		"albumArtist[1]": "YesDave",
+		"trackNumber[1]": "2",
+		"duration[1]":    "180",
+		"timestamp[1]":   "200",
+		"mbid[1]":        "b1a9c0e9",
+	}, batch.Format())
+}
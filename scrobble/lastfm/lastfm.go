@@ -1,7 +1,28 @@
 package lastfm
 
+import (
+	"net/url"
+	"time"
+)
+
 const UriApiSecBase = "https://ws.audioscrobbler.com/2.0/"
 
+// UriAuthBase is the page where users grant an application access to their
+// account.
+const UriAuthBase = "https://www.last.fm/api/auth/"
+
+// MaxLovedTracksPage is the maximum number of tracks returned per page of
+// user.getLovedTracks.
+const MaxLovedTracksPage = 1000
+
+// MaxScrobbleBatch is the maximum number of scrobbles accepted by a single
+// track.scrobble call.
+const MaxScrobbleBatch = 50
+
+// MaxScrobbleAge is how far back scrobbles are accepted, older ones are
+// ignored with IgnoredTimestampTooOld.
+const MaxScrobbleAge = 14 * 24 * time.Hour
+
 type Api struct {
 	uriBase string
 	params  *apiParams
@@ -22,12 +43,36 @@ func New(key, secret, uriBase string) *Api {
 	return &Api{uriBase: uriBase, params: &params}
 }
 
-func (api *Api) Scrobble(args ScrobbleArgs) error {
-	return api.callPost("track.scrobble", args, nil, true)
+// Scrobble submits up to MaxScrobbleBatch scrobbles in one request. The
+// result lists the scrobbles in the order they were given.
+func (api *Api) Scrobble(args ...ScrobbleArgs) (result TrackScrobble, err error) {
+	if len(args) == 1 {
+		err = api.callPost("track.scrobble", args[0], &result, true)
+	} else {
+		err = api.callPost("track.scrobble", ScrobbleBatch(args), &result, true)
+	}
+	return
+}
+
+func (api *Api) UpdateNowPlaying(args UpdateNowPlayingArgs) (result TrackUpdateNowPlaying, err error) {
+	err = api.callPost("track.updatenowplaying", args, &result, true)
+	return
 }
 
-func (api *Api) UpdateNowPlaying(args UpdateNowPlayingArgs) error {
-	return api.callPost("track.updatenowplaying", args, nil, true)
+// Love marks a track as loved by the user.
+func (api *Api) Love(args LoveArgs) error {
+	return api.callPost("track.love", args, nil, true)
+}
+
+// Unlove removes a track from the user's loved tracks.
+func (api *Api) Unlove(args LoveArgs) error {
+	return api.callPost("track.unlove", args, nil, true)
+}
+
+// GetLovedTracks returns a page of the tracks loved by user, starting at 1.
+func (api *Api) GetLovedTracks(user string, page int) (result UserGetLovedTracks, err error) {
+	err = api.callPost("user.getlovedtracks", GetLovedTracksArgs{user, page, MaxLovedTracksPage}, &result, false)
+	return
 }
 
 func (api *Api) Login(username, password string) error {
@@ -39,3 +84,45 @@ func (api *Api) Login(username, password string) error {
 	api.params.sk = result.Key
 	return nil
 }
+
+// GetToken fetches a request token to be authorized by the user at the URL
+// returned by AuthUrl.
+func (api *Api) GetToken() (string, error) {
+	var result AuthGetToken
+
+	if err := api.callPost("auth.gettoken", NoArgs{}, &result, false); err != nil {
+		return "", err
+	}
+	return result.Token, nil
+}
+
+// AuthUrl returns the page on authBase where the user authorizes token.
+func (api *Api) AuthUrl(authBase, token string) string {
+	if authBase == "" {
+		authBase = UriAuthBase
+	}
+
+	params := url.Values{}
+	params.Add("api_key", api.params.apikey)
+	params.Add("token", token)
+	return constructUrl(authBase, params)
+}
+
+// GetSession exchanges an authorized token for a session key.
+func (api *Api) GetSession(token string) error {
+	var result AuthGetSession
+
+	if err := api.callPost("auth.getsession", GetSessionArgs{token}, &result, false); err != nil {
+		return err
+	}
+	api.params.sk = result.Key
+	return nil
+}
+
+func (api *Api) SessionKey() string {
+	return api.params.sk
+}
+
+func (api *Api) SetSessionKey(sk string) {
+	api.params.sk = sk
+}
@@ -18,3 +18,70 @@ type AuthGetMobileSession struct {
 	Key        string `xml:"key"`  //session key
 	Subscriber bool   `xml:"subscriber"`
 }
+
+type AuthGetSession struct {
+	Name       string `xml:"name"` //username
+	Key        string `xml:"key"`  //session key
+	Subscriber bool   `xml:"subscriber"`
+}
+
+type AuthGetToken struct {
+	Token string `xml:",chardata"`
+}
+
+// Codes of the ignoredMessage element in scrobble and nowplaying responses.
+const (
+	IgnoredNone            = 0
+	IgnoredArtist          = 1
+	IgnoredTrack           = 2
+	IgnoredTimestampTooOld = 3
+	IgnoredTimestampTooNew = 4
+	IgnoredDailyLimit      = 5
+)
+
+type Corrected struct {
+	Corrected bool   `xml:"corrected,attr"`
+	Value     string `xml:",chardata"`
+}
+
+type IgnoredMessage struct {
+	Code    int    `xml:"code,attr"`
+	Message string `xml:",chardata"`
+}
+
+type Scrobble struct {
+	Track          Corrected      `xml:"track"`
+	Artist         Corrected      `xml:"artist"`
+	Album          Corrected      `xml:"album"`
+	AlbumArtist    Corrected      `xml:"albumArtist"`
+	Timestamp      int64          `xml:"timestamp"`
+	IgnoredMessage IgnoredMessage `xml:"ignoredMessage"`
+}
+
+type TrackScrobble struct {
+	Accepted  int        `xml:"accepted,attr"`
+	Ignored   int        `xml:"ignored,attr"`
+	Scrobbles []Scrobble `xml:"scrobble"`
+}
+
+type TrackUpdateNowPlaying struct {
+	Track          Corrected      `xml:"track"`
+	Artist         Corrected      `xml:"artist"`
+	Album          Corrected      `xml:"album"`
+	AlbumArtist    Corrected      `xml:"albumArtist"`
+	IgnoredMessage IgnoredMessage `xml:"ignoredMessage"`
+}
+
+type LovedTrack struct {
+	Name   string `xml:"name"`
+	Mbid   string `xml:"mbid"`
+	Artist struct {
+		Name string `xml:"name"`
+	} `xml:"artist"`
+}
+
+type UserGetLovedTracks struct {
+	Page       int          `xml:"page,attr"`
+	TotalPages int          `xml:"totalPages,attr"`
+	Tracks     []LovedTrack `xml:"track"`
+}
@@ -10,12 +10,38 @@ import (
 	"net/url"
 	"sort"
 	"strings"
+	"time"
 )
 
 const (
 	ApiResponseStatusFailed = "failed"
 )
 
+// Timeout limits the duration of a single API request.
+const Timeout = 30 * time.Second
+
+var httpClient = &http.Client{Timeout: Timeout}
+
+// Error codes returned by the API.
+const (
+	ErrInvalidService         = 2
+	ErrInvalidMethod          = 3
+	ErrAuthenticationFailed   = 4
+	ErrInvalidFormat          = 5
+	ErrInvalidParameters      = 6
+	ErrInvalidResource        = 7
+	ErrOperationFailed        = 8
+	ErrInvalidSessionKey      = 9
+	ErrInvalidApiKey          = 10
+	ErrServiceOffline         = 11
+	ErrInvalidMethodSignature = 13
+	ErrUnauthorizedToken      = 14
+	ErrTokenExpired           = 15
+	ErrTemporaryError         = 16
+	ErrSuspendedApiKey        = 26
+	ErrRateLimitExceeded      = 29
+)
+
 type Err struct {
 	Code    int
 	Message string
@@ -25,6 +51,33 @@ func (e *Err) Error() string {
 	return fmt.Sprintf("lastfm[%d]: %s", e.Code, e.Message)
 }
 
+// Temporary reports whether the request is likely to succeed when retried
+// later without changes.
+func (e *Err) Temporary() bool {
+	switch e.Code {
+	case ErrOperationFailed, ErrServiceOffline, ErrTemporaryError, ErrRateLimitExceeded:
+		return true
+	}
+	return false
+}
+
+// RateLimited reports whether the request was refused because too many
+// requests were made recently.
+func (e *Err) RateLimited() bool {
+	return e.Code == ErrRateLimitExceeded
+}
+
+// Permanent reports whether the request itself was rejected, so retrying it
+// will never succeed.
+func (e *Err) Permanent() bool {
+	return e.Code == ErrInvalidParameters || e.Code == ErrInvalidResource
+}
+
+// InvalidSession reports whether the session key has to be renewed.
+func (e *Err) InvalidSession() bool {
+	return e.Code == ErrInvalidSessionKey
+}
+
 func constructUrl(base string, params url.Values) string {
 	return base + "?" + params.Encode()
 }
@@ -98,7 +151,7 @@ func (api *Api) callPost(apiMethod string, args Args, result interface{}, withSe
 	sig := getSignature(tmp, api.params.secret)
 	postData.Add("api_sig", sig)
 
-	res, err := http.PostForm(uri, postData)
+	res, err := httpClient.PostForm(uri, postData)
 	if err != nil {
 		return err
 	}
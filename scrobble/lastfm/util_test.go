@@ -0,0 +1,86 @@
+package lastfm
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseScrobbleResponse(t *testing.T) {
+	assert := assert.New(t)
+
+	body := `<?xml version="1.0" encoding="utf-8"?>
+<lfm status="ok">
+  <scrobbles accepted="1" ignored="1">
+    <scrobble>
+      <track corrected="1">Track 01</track>
+      <artist corrected="0">John</artist>
+      <album corrected="0">Cool</album>
+      <albumArtist corrected="0"></albumArtist>
+      <timestamp>100</timestamp>
+      <ignoredMessage code="0"></ignoredMessage>
+    </scrobble>
+    <scrobble>
+      <track corrected="0">What2</track>
+      <artist corrected="0">Dave</artist>
+      <album corrected="0"></album>
+      <albumArtist corrected="0"></albumArtist>
+      <timestamp>200</timestamp>
+      <ignoredMessage code="3">Timestamp too old</ignoredMessage>
+    </scrobble>
+  </scrobbles>
+</lfm>`
+
+	var result TrackScrobble
+	assert.Nil(parseResponse(strings.NewReader(body), &result))
+
+	assert.Equal(1, result.Accepted)
+	assert.Equal(1, result.Ignored)
+	assert.Len(result.Scrobbles, 2)
+	assert.Equal(Corrected{true, "Track 01"}, result.Scrobbles[0].Track)
+	assert.Equal(int64(100), result.Scrobbles[0].Timestamp)
+	assert.Equal(IgnoredMessage{IgnoredTimestampTooOld, "Timestamp too old"}, result.Scrobbles[1].IgnoredMessage)
+}
+
+func TestParseLovedTracksResponse(t *testing.T) {
+	assert := assert.New(t)
+
+	body := `<?xml version="1.0" encoding="utf-8"?>
+<lfm status="ok">
+  <lovedtracks user="john" page="1" perPage="1000" totalPages="2" total="1001">
+    <track>
+      <name>Track 01</name>
+      <mbid>b1a9c0e9</mbid>
+      <url>https://www.last.fm/music/Dave/_/Track+01</url>
+      <date uts="1500000000">14 Jul 2017, 02:40</date>
+      <artist>
+        <name>Dave</name>
+        <mbid></mbid>
+        <url>https://www.last.fm/music/Dave</url>
+      </artist>
+    </track>
+  </lovedtracks>
+</lfm>`
+
+	var result UserGetLovedTracks
+	assert.Nil(parseResponse(strings.NewReader(body), &result))
+
+	assert.Equal(1, result.Page)
+	assert.Equal(2, result.TotalPages)
+	if assert.Len(result.Tracks, 1) {
+		assert.Equal("Track 01", result.Tracks[0].Name)
+		assert.Equal("b1a9c0e9", result.Tracks[0].Mbid)
+		assert.Equal("Dave", result.Tracks[0].Artist.Name)
+	}
+}
+
+func TestParseErrorResponse(t *testing.T) {
+	body := `<?xml version="1.0" encoding="utf-8"?>
+<lfm status="failed">
+  <error code="9">Invalid session key - Please re-authenticate</error>
+</lfm>`
+
+	err := parseResponse(strings.NewReader(body), nil)
+	assert.Equal(t, &Err{9, "Invalid session key - Please re-authenticate"}, err)
+}
@@ -0,0 +1,135 @@
+package scrobble
+
+import (
+	"log"
+
+	"github.com/softashell/mpd-scrobbler/scrobble/listenbrainz"
+)
+
+type listenbrainzScrobbler struct {
+	api      *listenbrainz.Api
+	loggedIn bool
+	name     string
+}
+
+func (api *listenbrainzScrobbler) Name() string {
+	return api.name
+}
+
+func (api *listenbrainzScrobbler) Close() error {
+	return nil
+}
+
+func (api *listenbrainzScrobbler) login() error {
+	if !api.loggedIn {
+		user, err := api.api.ValidateToken()
+		if err == nil {
+			log.Printf("[%s] Connected as %s", api.Name(), user)
+			api.loggedIn = true
+		}
+		return err
+	}
+	return nil
+}
+
+func (api *listenbrainzScrobbler) Scrobble(tracks ...Track) ([]Result, error) {
+	if err := api.login(); err != nil {
+		return nil, err
+	}
+
+	listens := make([]listenbrainz.Listen, len(tracks))
+	for i, track := range tracks {
+		listens[i] = newListen(track)
+		listens[i].ListenedAt = track.Timestamp.Unix()
+	}
+
+	listenType := listenbrainz.ListenTypeSingle
+	if len(listens) > 1 {
+		listenType = listenbrainz.ListenTypeImport
+	}
+
+	if err := api.api.SubmitListens(listenType, listens...); err != nil {
+		return nil, err
+	}
+
+	for _, track := range tracks {
+		log.Printf("[%s] Submitted: %s\n", api.Name(), track)
+	}
+
+	return acceptedResults(tracks), nil
+}
+
+func (api *listenbrainzScrobbler) NowPlaying(track Track) (Result, error) {
+	if err := api.login(); err != nil {
+		return Result{}, err
+	}
+
+	if err := api.api.SubmitListens(listenbrainz.ListenTypePlayingNow, newListen(track)); err != nil {
+		return Result{}, err
+	}
+
+	log.Printf("[%s] NowPlaying: %s\n", api.Name(), track)
+
+	return Result{Track: track}, nil
+}
+
+// Love sends feedback for the recording, which is only possible for tracks
+// tagged with a MusicBrainz recording id.
+func (api *listenbrainzScrobbler) Love(track Track, love bool) error {
+	if track.RecordingID == "" {
+		return rejectedError("no MusicBrainz recording id")
+	}
+	if err := api.login(); err != nil {
+		return err
+	}
+
+	score := listenbrainz.FeedbackNone
+	if love {
+		score = listenbrainz.FeedbackLove
+	}
+	if err := api.api.RecordingFeedback(track.RecordingID, score); err != nil {
+		return err
+	}
+
+	if love {
+		log.Printf("[%s] Loved: %s\n", api.Name(), track)
+	} else {
+		log.Printf("[%s] Unloved: %s\n", api.Name(), track)
+	}
+	return nil
+}
+
+func newListen(track Track) listenbrainz.Listen {
+	info := listenbrainz.AdditionalInfo{
+		DurationMs:         track.Duration * 1000,
+		ArtistNames:        track.Artists,
+		ReleaseArtistNames: track.AlbumArtists,
+		Date:               track.Date,
+		Tags:               track.Genres,
+		RecordingMbid:      track.RecordingID,
+		TrackMbid:          track.TrackID,
+		ReleaseMbid:        track.ReleaseID,
+		ReleaseGroupMbid:   track.ReleaseGroupID,
+		ArtistMbids:        track.ArtistIDs,
+		MediaPlayer:        "MPD",
+		SubmissionClient:   "mpd-scrobbler",
+	}
+	if track.TrackNumber > 0 {
+		info.TrackNumber = track.TrackNumber
+	}
+	if track.DiscNumber > 0 {
+		info.DiscNumber = track.DiscNumber
+	}
+	if track.AlbumArtist != "" && track.AlbumArtist != track.Artist {
+		info.ReleaseArtist = track.AlbumArtist
+	}
+
+	return listenbrainz.Listen{
+		TrackMetadata: listenbrainz.TrackMetadata{
+			ArtistName:     track.Artist,
+			TrackName:      track.Title,
+			ReleaseName:    track.Album,
+			AdditionalInfo: info,
+		},
+	}
+}
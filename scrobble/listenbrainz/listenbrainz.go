@@ -0,0 +1,143 @@
+// Package listenbrainz is a minimal client for the ListenBrainz listen
+// submission API.
+// The API reference can be found at https://listenbrainz.readthedocs.io/en/latest/users/api/
+package listenbrainz
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const UriApiBase = "https://api.listenbrainz.org"
+
+const (
+	ListenTypeSingle     = "single"
+	ListenTypePlayingNow = "playing_now"
+	ListenTypeImport     = "import"
+)
+
+// Timeout limits the duration of a single API request.
+const Timeout = 30 * time.Second
+
+var httpClient = &http.Client{Timeout: Timeout}
+
+type Err struct {
+	Code    int
+	Message string
+}
+
+func (e *Err) Error() string {
+	return fmt.Sprintf("listenbrainz[%d]: %s", e.Code, e.Message)
+}
+
+// Temporary reports whether the request is likely to succeed when retried
+// later without changes.
+func (e *Err) Temporary() bool {
+	return e.Code == http.StatusTooManyRequests || e.Code >= 500
+}
+
+// RateLimited reports whether the request was refused because too many
+// requests were made recently.
+func (e *Err) RateLimited() bool {
+	return e.Code == http.StatusTooManyRequests
+}
+
+// Permanent reports whether the submitted listens were rejected, so retrying
+// them will never succeed.
+func (e *Err) Permanent() bool {
+	return e.Code == http.StatusBadRequest
+}
+
+type Api struct {
+	uriBase string
+	token   string
+}
+
+func New(token, uriBase string) *Api {
+	if uriBase == "" {
+		uriBase = UriApiBase
+	}
+
+	return &Api{uriBase: strings.TrimSuffix(uriBase, "/"), token: token}
+}
+
+// SubmitListens sends listens of the given listen type. Single and
+// playing_now submissions must contain exactly one listen.
+func (api *Api) SubmitListens(listenType string, listens ...Listen) error {
+	body, err := json.Marshal(Submission{listenType, listens})
+	if err != nil {
+		return err
+	}
+
+	return api.call("POST", "/1/submit-listens", bytes.NewReader(body), nil)
+}
+
+// Feedback scores
+const (
+	FeedbackLove = 1
+	FeedbackNone = 0
+	FeedbackHate = -1
+)
+
+// RecordingFeedback sets the user's feedback for the recording with the
+// given MusicBrainz id to score.
+func (api *Api) RecordingFeedback(mbid string, score int) error {
+	body, err := json.Marshal(RecordingFeedback{mbid, score})
+	if err != nil {
+		return err
+	}
+
+	return api.call("POST", "/1/feedback/recording-feedback", bytes.NewReader(body), nil)
+}
+
+// ValidateToken checks the user token and returns the name of its owner.
+func (api *Api) ValidateToken() (string, error) {
+	var result ValidateToken
+
+	if err := api.call("GET", "/1/validate-token", nil, &result); err != nil {
+		return "", err
+	}
+	if !result.Valid {
+		return "", &Err{http.StatusUnauthorized, result.Message}
+	}
+	return result.UserName, nil
+}
+
+func (api *Api) call(method, path string, body io.Reader, result interface{}) error {
+	req, err := http.NewRequest(method, api.uriBase+path, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Token "+api.token)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	res, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+
+	defer res.Body.Close()
+	return parseResponse(res, result)
+}
+
+func parseResponse(res *http.Response, result interface{}) error {
+	if res.StatusCode != http.StatusOK {
+		var apiErr ApiError
+		if err := json.NewDecoder(res.Body).Decode(&apiErr); err != nil || apiErr.Error == "" {
+			return &Err{res.StatusCode, res.Status}
+		}
+		return &Err{res.StatusCode, apiErr.Error}
+	}
+
+	if result != nil {
+		return json.NewDecoder(res.Body).Decode(result)
+	}
+	return nil
+}
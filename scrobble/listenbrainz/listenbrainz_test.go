@@ -0,0 +1,81 @@
+package listenbrainz
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSubmitListens(t *testing.T) {
+	assert := assert.New(t)
+
+	var got Submission
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal("POST", r.Method)
+		assert.Equal("/1/submit-listens", r.URL.Path)
+		if r.Header.Get("Authorization") != "Token secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"code": 401, "error": "Invalid authorization token."}`))
+			return
+		}
+		assert.Nil(json.NewDecoder(r.Body).Decode(&got))
+		w.Write([]byte(`{"status": "ok"}`))
+	}))
+	defer srv.Close()
+
+	listen := Listen{
+		ListenedAt: 1500000000,
+		TrackMetadata: TrackMetadata{
+			ArtistName:  "John",
+			TrackName:   "Track 01",
+			ReleaseName: "Cool",
+		},
+	}
+
+	api := New("secret", srv.URL+"/")
+	assert.Nil(api.SubmitListens(ListenTypeSingle, listen))
+	assert.Equal(Submission{ListenTypeSingle, []Listen{listen}}, got)
+
+	err := New("wrong", srv.URL).SubmitListens(ListenTypeSingle, listen)
+	assert.Equal(&Err{401, "Invalid authorization token."}, err)
+}
+
+func TestValidateToken(t *testing.T) {
+	assert := assert.New(t)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal("/1/validate-token", r.URL.Path)
+		if r.Header.Get("Authorization") == "Token secret" {
+			w.Write([]byte(`{"code": 200, "message": "Token valid.", "valid": true, "user_name": "john"}`))
+		} else {
+			w.Write([]byte(`{"code": 200, "message": "Token invalid.", "valid": false}`))
+		}
+	}))
+	defer srv.Close()
+
+	user, err := New("secret", srv.URL).ValidateToken()
+	assert.Nil(err)
+	assert.Equal("john", user)
+
+	_, err = New("wrong", srv.URL).ValidateToken()
+	assert.Equal(&Err{401, "Token invalid."}, err)
+}
+
+func TestRecordingFeedback(t *testing.T) {
+	assert := assert.New(t)
+
+	var got RecordingFeedback
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal("POST", r.Method)
+		assert.Equal("/1/feedback/recording-feedback", r.URL.Path)
+		assert.Nil(json.NewDecoder(r.Body).Decode(&got))
+		w.Write([]byte(`{"status": "ok"}`))
+	}))
+	defer srv.Close()
+
+	assert.Nil(New("secret", srv.URL).RecordingFeedback("b1a9c0e9", FeedbackLove))
+	assert.Equal(RecordingFeedback{"b1a9c0e9", 1}, got)
+}
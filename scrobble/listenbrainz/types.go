@@ -0,0 +1,55 @@
+package listenbrainz
+
+type Submission struct {
+	ListenType string   `json:"listen_type"`
+	Payload    []Listen `json:"payload"`
+}
+
+type Listen struct {
+	ListenedAt    int64         `json:"listened_at,omitempty"`
+	TrackMetadata TrackMetadata `json:"track_metadata"`
+}
+
+type TrackMetadata struct {
+	ArtistName     string         `json:"artist_name"`
+	TrackName      string         `json:"track_name"`
+	ReleaseName    string         `json:"release_name,omitempty"`
+	AdditionalInfo AdditionalInfo `json:"additional_info"`
+}
+
+type AdditionalInfo struct {
+	TrackNumber        int32    `json:"tracknumber,omitempty"`
+	DiscNumber         int32    `json:"discnumber,omitempty"`
+	DurationMs         uint32   `json:"duration_ms,omitempty"`
+	ArtistNames        []string `json:"artist_names,omitempty"`
+	ReleaseArtist      string   `json:"release_artist_name,omitempty"`
+	ReleaseArtistNames []string `json:"release_artist_names,omitempty"`
+	Date               string   `json:"date,omitempty"` // release date as tagged
+	Tags               []string `json:"tags,omitempty"`
+	RecordingMbid      string   `json:"recording_mbid,omitempty"`
+	TrackMbid          string   `json:"track_mbid,omitempty"`
+	ReleaseMbid        string   `json:"release_mbid,omitempty"`
+	ReleaseGroupMbid   string   `json:"release_group_mbid,omitempty"`
+	ArtistMbids        []string `json:"artist_mbids,omitempty"`
+	MediaPlayer        string   `json:"media_player,omitempty"`
+	SubmissionClient   string   `json:"submission_client,omitempty"`
+}
+
+// RecordingFeedback loves (score 1), hates (-1) or clears (0) the feedback
+// for a recording.
+type RecordingFeedback struct {
+	RecordingMbid string `json:"recording_mbid"`
+	Score         int    `json:"score"`
+}
+
+type ApiError struct {
+	Code  int    `json:"code"`
+	Error string `json:"error"`
+}
+
+type ValidateToken struct {
+	Code     int    `json:"code"`
+	Message  string `json:"message"`
+	Valid    bool   `json:"valid"`
+	UserName string `json:"user_name"`
+}
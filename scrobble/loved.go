@@ -0,0 +1,35 @@
+package scrobble
+
+import (
+	"fmt"
+
+	"github.com/softashell/mpd-scrobbler/scrobble/lastfm"
+)
+
+// LovedTracks fetches every track the user of a Last.fm compatible service
+// has loved.
+func LovedTracks(conf Config) ([]Track, error) {
+	if conf.Type != "" && conf.Type != TypeLastfm {
+		return nil, fmt.Errorf("loved tracks can only be fetched from Last.fm compatible services")
+	}
+	if conf.Username == "" {
+		return nil, fmt.Errorf("username is required")
+	}
+
+	api := lastfm.New(conf.Key, conf.Secret, conf.URI)
+
+	var tracks []Track
+	for page := 1; ; page++ {
+		res, err := api.GetLovedTracks(conf.Username, page)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, t := range res.Tracks {
+			tracks = append(tracks, Track{Title: t.Name, Artist: t.Artist.Name, RecordingID: t.Mbid})
+		}
+		if page >= res.TotalPages {
+			return tracks, nil
+		}
+	}
+}
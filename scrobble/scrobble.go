@@ -1,108 +1,603 @@
 package scrobble
 
 import (
+	"fmt"
 	"log"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/softashell/mpd-scrobbler/scrobble/lastfm"
+	"github.com/softashell/mpd-scrobbler/scrobble/listenbrainz"
 )
 
+// BatchSize is the number of queued tracks submitted per request.
+const BatchSize = lastfm.MaxScrobbleBatch
+
 type Scrobbler interface {
-	Scrobble(title, artist, album, albumArtist string, trackNumber int32, duration uint32, timestamp time.Time) error
-	NowPlaying(title, artist, album, albumArtist string, trackNumber int32, duration uint32) error
+	// Scrobble submits up to BatchSize tracks in a single request and
+	// returns a result for each of them, in order.
+	Scrobble(tracks ...Track) ([]Result, error)
+	NowPlaying(track Track) (Result, error)
+	// Love adds track to the user's loved tracks, or removes it if love is
+	// false.
+	Love(track Track, love bool) error
 	Name() string
+	Close() error
 }
 
-func New(db Database, name, apiKey, secret, username, password, uriBase string) (Scrobbler, error) {
-	api := lastfm.New(apiKey, secret, uriBase)
+// Result describes how a service handled a submitted track.
+type Result struct {
+	Track          Track // as corrected by the service
+	Corrected      bool
+	IgnoredCode    int // one of the lastfm.Ignored* codes
+	IgnoredMessage string
+}
 
-	queue, err := db.Queue([]byte(name))
-	if err != nil {
-		return nil, err
+func (r Result) Ignored() bool {
+	return r.IgnoredCode != lastfm.IgnoredNone
+}
+
+func acceptedResults(tracks []Track) []Result {
+	results := make([]Result, len(tracks))
+	for i, track := range tracks {
+		results[i] = Result{Track: track}
 	}
+	return results
+}
 
-	scrobbler := &lastfmScrobbler{api, username, password, false, name}
+const (
+	TypeLastfm       = "lastfm"
+	TypeListenBrainz = "listenbrainz"
+)
 
-	log.Printf("[%s] Emptying queue\n", name)
-	for {
-		track, err := queue.Dequeue()
-		if err != nil {
-			if err != QUEUE_EMPTY {
-				log.Printf("[%s] Dequeue error: %v\n", name, err)
-				//queue.Enqueue(track)
-				//log.Printf("[%s] Queued: %s by %s\n", name, track.Title, track.Artist)
-			}
-			break
+// Config describes a single scrobbling service.
+type Config struct {
+	Type        string `toml:"type"` // TypeLastfm if empty
+	Key         string `toml:"key"`
+	Secret      string `toml:"secret"`
+	Username    string `toml:"username"`
+	Password    string `toml:"password"`
+	Token       string `toml:"token"` // listenbrainz user token
+	URI         string `toml:"uri"`
+	AuthURI     string `toml:"auth_uri"`     // web authentication page, lastfm.UriAuthBase if empty
+	MaxAge      string `toml:"max_age"`      // see MaxScrobbleAge
+	DedupWindow string `toml:"dedup_window"` // see DuplicateWindow
+}
+
+// MaxScrobbleAge returns how long ago a track may have been played to still
+// be submitted, or 0 if there is no limit. Unless set with max_age it is
+// lastfm.MaxScrobbleAge for Last.fm services and unlimited otherwise.
+func (conf Config) MaxScrobbleAge() (time.Duration, error) {
+	if conf.MaxAge == "" {
+		if conf.Type == "" || conf.Type == TypeLastfm {
+			return lastfm.MaxScrobbleAge, nil
 		}
+		return 0, nil
+	}
+	return ParseAge(conf.MaxAge)
+}
+
+// ParseAge parses a duration that may also be given in days, e.g. "14d".
+func ParseAge(s string) (time.Duration, error) {
+	if days := strings.TrimSuffix(s, "d"); days != s {
+		n, err := strconv.Atoi(days)
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("invalid age %q", s)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+
+	d, err := time.ParseDuration(s)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid age %q", s)
+	}
+	return d, nil
+}
 
-		err = scrobbler.Scrobble(
-			track.Title,
-			track.Artist,
-			track.Album,
-			track.AlbumArtist,
-			track.TrackNumber,
-			track.Duration,
-			track.Timestamp)
+func New(db Database, name string, conf Config) (Scrobbler, error) {
+	maxAge, err := conf.MaxScrobbleAge()
+	if err != nil {
+		return nil, err
+	}
+	dedupWindow, err := conf.DuplicateWindow()
+	if err != nil {
+		return nil, err
+	}
+
+	var scrobbler Scrobbler
+	switch conf.Type {
+	case "", TypeLastfm:
+		api := lastfm.New(conf.Key, conf.Secret, conf.URI)
+		sk, err := db.Session(name)
 		if err != nil {
-			log.Printf("[%s] Scrobble error: %v\n", name, err)
-			queue.Enqueue(track)
-			log.Printf("[%s] Queued: %s by %s\n", name, track.Title, track.Artist)
-			break
+			return nil, err
 		}
+		api.SetSessionKey(sk)
+		scrobbler = &lastfmScrobbler{api, db, conf.Username, conf.Password, sk != "", name}
+	case TypeListenBrainz:
+		api := listenbrainz.New(conf.Token, conf.URI)
+		scrobbler = &listenbrainzScrobbler{api, false, name}
+	default:
+		return nil, fmt.Errorf("unknown service type %q", conf.Type)
 	}
 
-	log.Printf("[%s] Emptying done\n", name)
+	api, err := newQueuedScrobbler(scrobbler, db)
+	if err != nil {
+		return nil, err
+	}
+	api.maxAge = maxAge
+	api.dedupWindow = dedupWindow
+	go api.flusher()
 
-	return &queuedScrobbler{scrobbler, queue}, nil
+	return api, nil
 }
 
 type queuedScrobbler struct {
 	Scrobbler
+	db      Database // keeps the history
+	queue   Queue
+	ignored Queue // tracks the service refused to accept
+	dead    Queue // tracks the service rejected as invalid
+	loves   Queue // tracks to love once the service is back
+	unloves Queue
+	expired Queue         // tracks too old for the service
+	maxAge  time.Duration // 0 for no limit
+
+	dedupWindow time.Duration // 0 to submit duplicates
+
+	// loveLock keeps at most one love or unlove queued per track, so the
+	// order of the two queues doesn't matter.
+	loveLock sync.Mutex
+
+	unsavedLock sync.Mutex
+	unsaved     []unsaved // tracks that couldn't be written to their queue yet
+
+	lock   sync.Mutex // serializes submissions of the caller and the flusher
+	notify chan error
+	quit   chan struct{}
+	done   chan struct{}
+}
+
+type unsaved struct {
 	queue Queue
+	track Track
+}
+
+// queueSuffixes name the queues of a service after it: pending scrobbles,
+// ignored and rejected tracks, pending loves and unloves, and tracks that
+// were played too long ago.
+var queueSuffixes = []string{"", ".ignored", ".dead", ".love", ".unlove", ".expired"}
+
+// QueueNames returns the names of the queues kept for the named service.
+func QueueNames(service string) []string {
+	names := make([]string, len(queueSuffixes))
+	for i, suffix := range queueSuffixes {
+		names[i] = service + suffix
+	}
+	return names
 }
 
-func (api *queuedScrobbler) Scrobble(title, artist, album, albumArtist string, trackNumber int32, duration uint32, timestamp time.Time) (err error) {
-	track, err := Track{
-		Title:       title,
-		Artist:      artist,
-		Album:       album,
-		AlbumArtist: albumArtist,
-		TrackNumber: trackNumber,
-		Duration:    duration,
-		Timestamp:   timestamp,
+// newQueuedScrobbler wraps scrobbler with the queues named after it.
+func newQueuedScrobbler(scrobbler Scrobbler, db Database) (*queuedScrobbler, error) {
+	queues := make([]Queue, len(queueSuffixes))
+	for i, name := range QueueNames(scrobbler.Name()) {
+		var err error
+		if queues[i], err = db.Queue([]byte(name)); err != nil {
+			return nil, err
+		}
+	}
+
+	return &queuedScrobbler{
+		Scrobbler: scrobbler,
+		db:        db,
+		queue:     queues[0],
+		ignored:   queues[1],
+		dead:      queues[2],
+		loves:     queues[3],
+		unloves:   queues[4],
+		expired:   queues[5],
+		notify:    make(chan error, 1),
+		quit:      make(chan struct{}),
+		done:      make(chan struct{}),
 	}, nil
+}
+
+func (api *queuedScrobbler) Scrobble(tracks ...Track) ([]Result, error) {
+	api.lock.Lock()
+	results, err := api.submit(tracks)
+	api.lock.Unlock()
+
+	if err != nil && classify(err) == errDrop {
+		api.notifyFlusher(nil)
+	} else {
+		api.notifyFlusher(err)
+	}
+	return results, err
+}
+
+func (api *queuedScrobbler) NowPlaying(track Track) (Result, error) {
+	api.lock.Lock()
+	defer api.lock.Unlock()
+	return api.Scrobbler.NowPlaying(track)
+}
+
+func (api *queuedScrobbler) Love(track Track, love bool) error {
+	api.lock.Lock()
+	api.loveLock.Lock()
+	api.dropLoves(track) // this one overrides them
+	api.loveLock.Unlock()
+	err := api.love(track, love)
+	api.lock.Unlock()
+
+	if err != nil && classify(err) == errDrop {
+		api.notifyFlusher(nil)
+	} else {
+		api.notifyFlusher(err)
+	}
+	return err
+}
+
+// Close stops the flusher, queued tracks stay in the database.
+func (api *queuedScrobbler) Close() error {
+	close(api.quit)
+	<-api.done
+
+	if api.saveUnsaved() != nil {
+		api.unsavedLock.Lock()
+		for _, u := range api.unsaved {
+			log.Printf("[%s] Lost: %s\n", api.Name(), u.track)
+		}
+		api.unsavedLock.Unlock()
+	}
+	return api.Scrobbler.Close()
+}
+
+// flush submits queued tracks in batches until the queue is empty or a
+// submission fails, then sends the queued loves.
+func (api *queuedScrobbler) flush() error {
+	if err := api.saveUnsaved(); err != nil {
+		return err
+	}
+
 	for {
-		err = api.Scrobbler.Scrobble(
-			track.Title,
-			track.Artist,
-			track.Album,
-			track.AlbumArtist,
-			track.TrackNumber,
-			track.Duration,
-			track.Timestamp)
+		select {
+		case <-api.quit:
+			return nil
+		default:
+		}
+
+		api.lock.Lock()
+		tracks, err := api.queue.DequeueN(BatchSize)
 		if err != nil {
-			break
+			api.lock.Unlock()
+			if err != QUEUE_EMPTY {
+				log.Printf("[%s] Dequeue error: %v\n", api.Name(), err)
+				return err
+			}
+			return api.flushLoves()
+		}
+
+		log.Printf("[%s] Submitting %d queued tracks\n", api.Name(), len(tracks))
+		_, err = api.submit(tracks)
+		api.lock.Unlock()
+		if err != nil && classify(err) != errDrop {
+			return err
+		}
+	}
+}
+
+// flushLoves sends queued loves and then unloves one at a time until both
+// queues are empty or a request fails.
+func (api *queuedScrobbler) flushLoves() error {
+	if err := api.flushLoveQueue(api.loves, true); err != nil {
+		return err
+	}
+	return api.flushLoveQueue(api.unloves, false)
+}
+
+func (api *queuedScrobbler) flushLoveQueue(queue Queue, love bool) error {
+	for {
+		select {
+		case <-api.quit:
+			return nil
+		default:
 		}
-		track, err = api.queue.Dequeue()
+
+		api.lock.Lock()
+		api.loveLock.Lock()
+		track, err := queue.Dequeue()
+		api.loveLock.Unlock()
 		if err != nil {
+			api.lock.Unlock()
 			if err != QUEUE_EMPTY {
 				log.Printf("[%s] Dequeue error: %v\n", api.Name(), err)
+				return err
 			}
 			return nil
 		}
+
+		err = api.love(track, love)
+		api.lock.Unlock()
+		if err != nil && classify(err) != errDrop {
+			return err
+		}
 	}
+}
 
-	if err != nil {
+// submit scrobbles tracks, requeueing them if the service is unavailable and
+// moving them to the dead-letter queue if they were rejected. Results are
+// returned only for the tracks that were submitted.
+func (api *queuedScrobbler) submit(tracks []Track) ([]Result, error) {
+	if tracks = api.dropDuplicates(api.dropExpired(tracks)); len(tracks) == 0 {
+		return nil, nil
+	}
+
+	results, err := api.Scrobbler.Scrobble(tracks...)
+	if err == nil {
+		return results, api.handleResults(tracks, results)
+	}
+
+	if classify(err) != errDrop {
 		log.Printf("[%s] Scrobble error: %v\n", api.Name(), err)
-		api.queue.Enqueue(track)
-		log.Printf("[%s] Queued: %s by %s\n", api.Name(), title, artist)
+		api.enqueue(tracks)
+		return nil, err
+	}
+
+	if len(tracks) == 1 {
+		log.Printf("[%s] Rejected: %s: %v\n", api.Name(), tracks[0], err)
+		api.push(api.dead, tracks[0])
+		api.record(tracks, []HistoryResult{{
+			Status:    StatusRejected,
+			Message:   err.Error(),
+			Submitted: time.Now().UTC(),
+		}})
+		return nil, err
 	}
 
+	// one bad track fails the whole batch, so find it by sending them one
+	// at a time
+	results = nil
+	for i, track := range tracks {
+		res, terr := api.submit([]Track{track})
+		results = append(results, res...)
+		if terr != nil {
+			if classify(terr) != errDrop {
+				api.enqueue(tracks[i+1:])
+				return results, terr
+			}
+			err = terr
+		}
+	}
+	return results, err
+}
+
+// handleResults requeues tracks that hit the daily limit, keeps the ones
+// that were ignored for good and adds the rest to the history. Hitting the
+// limit is returned as a rate limit error, so the flusher backs off instead
+// of submitting the requeued tracks right away.
+func (api *queuedScrobbler) handleResults(tracks []Track, results []Result) error {
+	var err error
+	var requeue, expired, ignored, recorded []Track
+	var history []HistoryResult
+	for i, result := range results {
+		switch result.IgnoredCode {
+		case lastfm.IgnoredNone:
+		case lastfm.IgnoredDailyLimit:
+			requeue = append(requeue, tracks[i])
+			err = &lastfm.Err{Code: lastfm.ErrRateLimitExceeded, Message: "daily scrobble limit reached"}
+			continue
+		case lastfm.IgnoredTimestampTooOld:
+			expired = append(expired, tracks[i])
+			continue
+		default:
+			ignored = append(ignored, tracks[i])
+		}
+		recorded = append(recorded, tracks[i])
+		history = append(history, newHistoryResult(result))
+	}
+
+	api.enqueue(requeue)
+	api.expire(expired)
+	api.push(api.ignored, ignored...)
+	api.record(recorded, history)
 	return err
 }
 
+// dropExpired moves tracks older than the service accepts to the expired
+// queue and returns the rest.
+func (api *queuedScrobbler) dropExpired(tracks []Track) []Track {
+	if api.maxAge == 0 {
+		return tracks
+	}
+
+	cutoff := time.Now().Add(-api.maxAge)
+	var keep, expired []Track
+	for _, track := range tracks {
+		if track.Timestamp.Before(cutoff) {
+			expired = append(expired, track)
+		} else {
+			keep = append(keep, track)
+		}
+	}
+	api.expire(expired)
+	return keep
+}
+
+func (api *queuedScrobbler) expire(tracks []Track) {
+	if len(tracks) == 0 {
+		return
+	}
+
+	api.push(api.expired, tracks...)
+	results := make([]HistoryResult, len(tracks))
+	for i, track := range tracks {
+		log.Printf("[%s] Expired: %s\n", api.Name(), track)
+		results[i] = HistoryResult{Status: StatusExpired, Submitted: time.Now().UTC()}
+	}
+	api.record(tracks, results)
+}
+
+// record adds the results of a batch to the history in one transaction.
+func (api *queuedScrobbler) record(tracks []Track, results []HistoryResult) {
+	if len(tracks) == 0 {
+		return
+	}
+	if err := api.db.Record(api.Name(), tracks, results); err != nil {
+		log.Printf("[%s] History error: %v\n", api.Name(), err)
+	}
+}
+
+// love sends a love or unlove, queueing it if the service is unavailable,
+// unless a newer one for the track was queued while it was being sent.
+func (api *queuedScrobbler) love(track Track, love bool) error {
+	err := api.Scrobbler.Love(track, love)
+	if err == nil {
+		return nil
+	}
+	if classify(err) == errDrop {
+		log.Printf("[%s] Love rejected: %s: %v\n", api.Name(), track, err)
+		return err
+	}
+
+	log.Printf("[%s] Love error: %v\n", api.Name(), err)
+	api.loveLock.Lock()
+	defer api.loveLock.Unlock()
+	if api.lovesQueued(track) {
+		log.Printf("[%s] Dropping love, a newer one is queued: %s\n", api.Name(), track)
+		return err
+	}
+	api.pushLove(track, love)
+	return err
+}
+
+// enqueueLove queues a love or unlove in place of any queued for the track.
+func (api *queuedScrobbler) enqueueLove(track Track, love bool) {
+	api.loveLock.Lock()
+	defer api.loveLock.Unlock()
+	api.dropLoves(track)
+	api.pushLove(track, love)
+}
+
+func (api *queuedScrobbler) pushLove(track Track, love bool) {
+	action, queue := "love", api.loves
+	if !love {
+		action, queue = "unlove", api.unloves
+	}
+
+	api.push(queue, track)
+	log.Printf("[%s] Queued %s: %s\n", api.Name(), action, track)
+}
+
+// dropLoves removes the queued loves and unloves of track, including ones
+// kept in memory. loveLock must be held.
+func (api *queuedScrobbler) dropLoves(track Track) {
+	for _, queue := range []Queue{api.loves, api.unloves} {
+		ids, err := loveIDs(queue, track)
+		if err == nil && len(ids) > 0 {
+			err = queue.Delete(ids...)
+		}
+		if err != nil {
+			log.Printf("[%s] Dequeue error: %v\n", api.Name(), err)
+		}
+	}
+
+	api.unsavedLock.Lock()
+	keep := api.unsaved[:0]
+	for _, u := range api.unsaved {
+		if (u.queue != api.loves && u.queue != api.unloves) || !sameSong(u.track, track) {
+			keep = append(keep, u)
+		}
+	}
+	api.unsaved = keep
+	api.unsavedLock.Unlock()
+}
+
+// lovesQueued tells if a love or unlove of track is queued. loveLock must be
+// held.
+func (api *queuedScrobbler) lovesQueued(track Track) bool {
+	for _, queue := range []Queue{api.loves, api.unloves} {
+		if ids, _ := loveIDs(queue, track); len(ids) > 0 {
+			return true
+		}
+	}
+
+	api.unsavedLock.Lock()
+	defer api.unsavedLock.Unlock()
+	for _, u := range api.unsaved {
+		if (u.queue == api.loves || u.queue == api.unloves) && sameSong(u.track, track) {
+			return true
+		}
+	}
+	return false
+}
+
+// loveIDs returns the ids of the entries of queue for track.
+func loveIDs(queue Queue, track Track) (ids []uint64, err error) {
+	err = queue.Each(func(id uint64, queued Track) error {
+		if sameSong(queued, track) {
+			ids = append(ids, id)
+		}
+		return nil
+	})
+	return
+}
+
+// sameSong tells if loving a and b loves the same song, which services know
+// by artist and title.
+func sameSong(a, b Track) bool {
+	return a.Artist == b.Artist && a.Title == b.Title
+}
+
+func (api *queuedScrobbler) enqueue(tracks []Track) {
+	api.push(api.queue, tracks...)
+	for _, track := range tracks {
+		log.Printf("[%s] Queued: %s\n", api.Name(), track)
+	}
+}
+
+// push adds tracks to queue. If the database can't be written, e.g. while
+// another process holds its lock, the tracks are kept in memory until the
+// flusher manages to write them.
+func (api *queuedScrobbler) push(queue Queue, tracks ...Track) {
+	if len(tracks) == 0 {
+		return
+	}
+	err := queue.Enqueue(tracks...)
+	if err == nil {
+		return
+	}
+
+	api.unsavedLock.Lock()
+	for _, track := range tracks {
+		log.Printf("[%s] Enqueue error, keeping %s in memory: %v\n", api.Name(), track, err)
+		api.unsaved = append(api.unsaved, unsaved{queue, track})
+	}
+	api.unsavedLock.Unlock()
+	api.notifyFlusher(err)
+}
+
+// saveUnsaved writes the tracks kept in memory to their queues, stopping at
+// the first error.
+func (api *queuedScrobbler) saveUnsaved() error {
+	api.unsavedLock.Lock()
+	defer api.unsavedLock.Unlock()
+
+	for len(api.unsaved) > 0 {
+		u := api.unsaved[0]
+		if err := u.queue.Enqueue(u.track); err != nil {
+			log.Printf("[%s] Enqueue error: %v\n", api.Name(), err)
+			return err
+		}
+		api.unsaved = api.unsaved[1:]
+	}
+	return nil
+}
+
 type lastfmScrobbler struct {
 	api      *lastfm.Api
+	db       Database // keeps the session key across restarts
 	username string
 	password string
 	loggedIn bool
@@ -113,57 +608,166 @@ func (api *lastfmScrobbler) Name() string {
 	return api.name
 }
 
+func (api *lastfmScrobbler) Close() error {
+	return nil
+}
+
 func (api *lastfmScrobbler) login() error {
 	if !api.loggedIn {
+		if api.password == "" {
+			return fmt.Errorf("not authorized, run `mpd-scrobbler auth %s`", api.Name())
+		}
 		err := api.api.Login(api.username, api.password)
-		if err == nil {
-			log.Printf("[%s] Connected", api.Name())
-			api.loggedIn = true
+		if err != nil {
+			return err
+		}
+		log.Printf("[%s] Connected", api.Name())
+		api.loggedIn = true
+
+		if err := api.db.SetSession(api.Name(), api.api.SessionKey()); err != nil {
+			log.Printf("[%s] Failed to store session key: %v\n", api.Name(), err)
 		}
-		return err
 	}
 	return nil
 }
 
-func (api *lastfmScrobbler) Scrobble(title, artist, album, albumArtist string, trackNumber int32, duration uint32, timestamp time.Time) error {
+// call runs fn with a valid session, logging in again once if the session
+// key was rejected.
+func (api *lastfmScrobbler) call(fn func() error) error {
 	if err := api.login(); err != nil {
 		return err
 	}
 
-	err := api.api.Scrobble(lastfm.ScrobbleArgs{
-		Track:       title,
-		Artist:      artist,
-		Album:       album,
-		AlbumArtist: albumArtist,
-		TrackNumber: trackNumber,
-		Duration:    duration,
-		Timestamp:   timestamp.Unix(),
+	err := fn()
+	if err == nil || classify(err) != errRelogin {
+		return err
+	}
+
+	log.Printf("[%s] Session expired, logging in again\n", api.Name())
+	api.loggedIn = false
+	if err := api.db.SetSession(api.Name(), ""); err != nil {
+		log.Printf("[%s] Failed to remove session key: %v\n", api.Name(), err)
+	}
+	if err := api.login(); err != nil {
+		return err
+	}
+	return fn()
+}
+
+func (api *lastfmScrobbler) Scrobble(tracks ...Track) ([]Result, error) {
+	args := make([]lastfm.ScrobbleArgs, len(tracks))
+	for i, track := range tracks {
+		args[i] = lastfm.ScrobbleArgs{
+			Track:       track.Title,
+			Artist:      track.Artist,
+			Album:       track.Album,
+			AlbumArtist: track.AlbumArtist,
+			TrackNumber: track.TrackNumber,
+			Duration:    track.Duration,
+			Timestamp:   track.Timestamp.Unix(),
+			Mbid:        track.RecordingID,
+		}
+	}
+
+	var res lastfm.TrackScrobble
+	err := api.call(func() (err error) {
+		res, err = api.api.Scrobble(args...)
+		return
 	})
+	if err != nil {
+		return nil, err
+	}
 
-	if err == nil {
-		log.Printf("[%s] Submitted: %s by %s\n", api.Name(), title, artist)
+	results := acceptedResults(tracks)
+	for i := 0; i < len(results) && i < len(res.Scrobbles); i++ {
+		sc := res.Scrobbles[i]
+		results[i] = lastfmResult(tracks[i], sc.Track, sc.Artist, sc.Album, sc.AlbumArtist, sc.IgnoredMessage)
 	}
 
-	return err
+	for _, result := range results {
+		api.logResult("Submitted", result)
+	}
+
+	return results, nil
 }
 
-func (api *lastfmScrobbler) NowPlaying(title, artist, album, albumArtist string, trackNumber int32, duration uint32) error {
-	if err := api.login(); err != nil {
-		return err
+func (api *lastfmScrobbler) NowPlaying(track Track) (Result, error) {
+	var res lastfm.TrackUpdateNowPlaying
+	err := api.call(func() (err error) {
+		res, err = api.api.UpdateNowPlaying(lastfm.UpdateNowPlayingArgs{
+			Track:       track.Title,
+			Artist:      track.Artist,
+			Album:       track.Album,
+			AlbumArtist: track.AlbumArtist,
+			TrackNumber: track.TrackNumber,
+			Duration:    track.Duration,
+			Mbid:        track.RecordingID,
+		})
+		return
+	})
+	if err != nil {
+		return Result{}, err
 	}
 
-	err := api.api.UpdateNowPlaying(lastfm.UpdateNowPlayingArgs{
-		Track:       title,
-		Artist:      artist,
-		Album:       album,
-		AlbumArtist: albumArtist,
-		TrackNumber: trackNumber,
-		Duration:    duration,
+	result := lastfmResult(track, res.Track, res.Artist, res.Album, res.AlbumArtist, res.IgnoredMessage)
+	api.logResult("NowPlaying", result)
+
+	return result, nil
+}
+
+func (api *lastfmScrobbler) Love(track Track, love bool) error {
+	args := lastfm.LoveArgs{Artist: track.Artist, Track: track.Title}
+	err := api.call(func() error {
+		if love {
+			return api.api.Love(args)
+		}
+		return api.api.Unlove(args)
 	})
+	if err != nil {
+		return err
+	}
 
-	if err == nil {
-		log.Printf("[%s] NowPlaying: %s by %s\n", api.Name(), title, artist)
+	if love {
+		log.Printf("[%s] Loved: %s\n", api.Name(), track)
+	} else {
+		log.Printf("[%s] Unloved: %s\n", api.Name(), track)
 	}
+	return nil
+}
 
-	return err
+func (api *lastfmScrobbler) logResult(action string, result Result) {
+	track := result.Track
+	switch {
+	case result.Ignored():
+		log.Printf("[%s] Ignored: %s (%d: %s)\n", api.Name(), track, result.IgnoredCode, result.IgnoredMessage)
+	case result.Corrected:
+		log.Printf("[%s] %s: %s (corrected)\n", api.Name(), action, track)
+	default:
+		log.Printf("[%s] %s: %s\n", api.Name(), action, track)
+	}
+}
+
+func lastfmResult(track Track, title, artist, album, albumArtist lastfm.Corrected, ignored lastfm.IgnoredMessage) Result {
+	result := Result{
+		Track:          track,
+		IgnoredCode:    ignored.Code,
+		IgnoredMessage: strings.TrimSpace(ignored.Message),
+	}
+
+	for _, c := range []struct {
+		field     *string
+		corrected lastfm.Corrected
+	}{
+		{&result.Track.Title, title},
+		{&result.Track.Artist, artist},
+		{&result.Track.Album, album},
+		{&result.Track.AlbumArtist, albumArtist},
+	} {
+		if c.corrected.Corrected {
+			*c.field = c.corrected.Value
+			result.Corrected = true
+		}
+	}
+
+	return result
 }
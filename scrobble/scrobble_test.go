@@ -0,0 +1,408 @@
+package scrobble
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/softashell/mpd-scrobbler/scrobble/lastfm"
+)
+
+// fakeScrobbler rejects tracks titled "bad", fails everything while down and
+// ignores everything once the daily limit is reached.
+type fakeScrobbler struct {
+	name      string
+	down      bool
+	limited   bool
+	attempts  int // submitted tracks, including ignored ones
+	submitted []Track
+	loved     map[string]bool // by title
+}
+
+func (f *fakeScrobbler) Name() string { return "fake" + f.name }
+
+func (f *fakeScrobbler) Scrobble(tracks ...Track) ([]Result, error) {
+	if f.down {
+		return nil, &lastfm.Err{Code: lastfm.ErrServiceOffline, Message: "offline"}
+	}
+	for _, track := range tracks {
+		if track.Title == "bad" {
+			return nil, &lastfm.Err{Code: lastfm.ErrInvalidParameters, Message: "invalid"}
+		}
+	}
+	f.attempts += len(tracks)
+	if f.limited {
+		results := acceptedResults(tracks)
+		for i := range results {
+			results[i].IgnoredCode = lastfm.IgnoredDailyLimit
+		}
+		return results, nil
+	}
+	f.submitted = append(f.submitted, tracks...)
+	return acceptedResults(tracks), nil
+}
+
+func (f *fakeScrobbler) Close() error { return nil }
+
+func (f *fakeScrobbler) NowPlaying(track Track) (Result, error) {
+	return Result{Track: track}, nil
+}
+
+func (f *fakeScrobbler) Love(track Track, love bool) error {
+	if f.down {
+		return &lastfm.Err{Code: lastfm.ErrServiceOffline, Message: "offline"}
+	}
+	if f.loved == nil {
+		f.loved = map[string]bool{}
+	}
+	f.loved[track.Title] = love
+	return nil
+}
+
+func TestQueuedScrobblerDeadLetter(t *testing.T) {
+	path := filepath.Join(os.TempDir(), "scrobble_test")
+	db, _ := Open(path)
+	defer os.Remove(path)
+	defer db.Close()
+
+	assert := assert.New(t)
+
+	fake := &fakeScrobbler{down: true}
+	api, _ := newQueuedScrobbler(fake, db)
+
+	t1 := Track{Title: "good", Artist: "John", Album: "Cool", AlbumArtist: "John", TrackNumber: 1, Timestamp: time.Now().UTC()}
+	t2 := Track{Title: "bad", Artist: "John", Album: "Cool", AlbumArtist: "John", TrackNumber: 2, Timestamp: time.Now().UTC()}
+	t3 := Track{Title: "fine", Artist: "John", Album: "Cool", AlbumArtist: "John", TrackNumber: 3, Timestamp: time.Now().UTC()}
+
+	_, err := api.Scrobble(t1)
+	assert.NotNil(err)
+	_, err = api.Scrobble(t2)
+	assert.NotNil(err)
+
+	fake.down = false
+	_, err = api.Scrobble(t3)
+	assert.Nil(err)
+	assert.Nil(api.flush())
+
+	assert.Equal([]Track{t3, t1}, fake.submitted)
+
+	_, err = api.queue.Dequeue()
+	assert.Equal(QUEUE_EMPTY, err)
+
+	r, err := api.dead.Dequeue()
+	assert.Nil(err)
+	assert.Equal(t2, r)
+
+	entries, err := db.History(t1.Timestamp, time.Now().Add(time.Second))
+	assert.Nil(err)
+	if assert.Len(entries, 3) {
+		assert.Equal(StatusAccepted, entries[0].Results["fake"].Status)
+		assert.Equal(StatusRejected, entries[1].Results["fake"].Status)
+		assert.Equal(StatusAccepted, entries[2].Results["fake"].Status)
+	}
+}
+
+func TestQueuedScrobblerLove(t *testing.T) {
+	path := filepath.Join(os.TempDir(), "scrobble_love_test")
+	db, _ := Open(path)
+	defer os.Remove(path)
+	defer db.Close()
+
+	assert := assert.New(t)
+
+	fake := &fakeScrobbler{down: true}
+	api, _ := newQueuedScrobbler(fake, db)
+
+	assert.NotNil(api.Love(Track{Title: "one", Artist: "John"}, true))
+	assert.NotNil(api.Love(Track{Title: "two", Artist: "John"}, false))
+	assert.Nil(fake.loved)
+
+	fake.down = false
+	assert.Nil(api.flush())
+	assert.Equal(map[string]bool{"one": true, "two": false}, fake.loved)
+
+	_, err := api.loves.Dequeue()
+	assert.Equal(QUEUE_EMPTY, err)
+	_, err = api.unloves.Dequeue()
+	assert.Equal(QUEUE_EMPTY, err)
+}
+
+func TestQueuedScrobblerLoveOrder(t *testing.T) {
+	path := filepath.Join(os.TempDir(), "scrobble_love_order_test")
+	db, _ := Open(path)
+	defer os.Remove(path)
+	defer db.Close()
+
+	assert := assert.New(t)
+
+	fake := &fakeScrobbler{down: true}
+	api, _ := newQueuedScrobbler(fake, db)
+
+	// the last action while the service is down wins
+	one := Track{Title: "one", Artist: "John"}
+	two := Track{Title: "two", Artist: "John"}
+	assert.NotNil(api.Love(one, false))
+	assert.NotNil(api.Love(one, true))
+	assert.NotNil(api.Love(two, true))
+	assert.NotNil(api.Love(two, false))
+
+	fake.down = false
+	assert.Nil(api.flush())
+	assert.Equal(map[string]bool{"one": true, "two": false}, fake.loved)
+
+	// sending one directly drops the queued one
+	fake.down = true
+	assert.NotNil(api.Love(one, false))
+	fake.down = false
+	assert.Nil(api.Love(one, true))
+	assert.Nil(api.flush())
+	assert.Equal(true, fake.loved["one"])
+
+	// a replay that fails doesn't override one queued meanwhile
+	api.enqueueLove(two, true)
+	fake.down = true
+	assert.NotNil(api.love(two, false))
+	fake.down = false
+	assert.Nil(api.flush())
+	assert.Equal(true, fake.loved["two"])
+}
+
+func TestQueuedScrobblerDailyLimit(t *testing.T) {
+	path := filepath.Join(os.TempDir(), "scrobble_limit_test")
+	db, _ := Open(path)
+	defer os.Remove(path)
+	defer db.Close()
+
+	assert := assert.New(t)
+
+	fake := &fakeScrobbler{limited: true}
+	api, _ := newQueuedScrobbler(fake, db)
+
+	for i := 0; i < 3; i++ {
+		assert.Nil(api.queue.Enqueue(Track{Title: "Track", Artist: "John", TrackNumber: int32(i), Timestamp: time.Now().UTC()}))
+	}
+
+	// the flush stops after one attempt and backs off for the rate limit
+	err := api.flush()
+	assert.NotNil(err)
+	assert.Equal(3, fake.attempts)
+	assert.Equal(rateLimitBackoff, nextBackoff(0, err))
+
+	n, _ := api.queue.Len()
+	assert.Equal(3, n)
+	assert.Empty(fake.submitted)
+}
+
+// lockedQueue fails to enqueue while locked, like a queue whose database is
+// held by another process.
+type lockedQueue struct {
+	Queue
+	locked bool
+}
+
+func (q *lockedQueue) Enqueue(tracks ...Track) error {
+	if q.locked {
+		return errors.New("locked")
+	}
+	return q.Queue.Enqueue(tracks...)
+}
+
+func TestQueuedScrobblerUnsaved(t *testing.T) {
+	path := filepath.Join(os.TempDir(), "scrobble_unsaved_test")
+	db, _ := Open(path)
+	defer os.Remove(path)
+	defer db.Close()
+
+	assert := assert.New(t)
+
+	fake := &fakeScrobbler{}
+	api, _ := newQueuedScrobbler(fake, db)
+	q := &lockedQueue{api.queue, true}
+	api.queue = q
+
+	track := Track{Title: "Track", Artist: "John", TrackNumber: -1, Timestamp: time.Now().UTC()}
+	api.enqueue([]Track{track})
+	assert.Len(api.unsaved, 1)
+	assert.NotNil(api.flush())
+
+	q.locked = false
+	assert.Nil(api.flush())
+	assert.Empty(api.unsaved)
+	assert.Equal([]Track{track}, fake.submitted)
+}
+
+func TestQueuedScrobblerExpired(t *testing.T) {
+	path := filepath.Join(os.TempDir(), "scrobble_expired_test")
+	db, _ := Open(path)
+	defer os.Remove(path)
+	defer db.Close()
+
+	assert := assert.New(t)
+
+	fake := &fakeScrobbler{}
+	api, _ := newQueuedScrobbler(fake, db)
+	api.maxAge = lastfm.MaxScrobbleAge
+
+	old := Track{Title: "old", Artist: "John", TrackNumber: -1, Timestamp: time.Now().Add(-15 * 24 * time.Hour).UTC()}
+	recent := Track{Title: "recent", Artist: "John", TrackNumber: -1, Timestamp: time.Now().UTC()}
+
+	assert.Nil(api.queue.Enqueue(old))
+	assert.Nil(api.queue.Enqueue(recent))
+	assert.Nil(api.flush())
+	assert.Equal([]Track{recent}, fake.submitted)
+
+	r, err := api.expired.Dequeue()
+	assert.Nil(err)
+	assert.Equal(old, r)
+}
+
+func TestQueuedScrobblerDuplicate(t *testing.T) {
+	path := filepath.Join(os.TempDir(), "scrobble_dedup_test")
+	db, _ := Open(path)
+	defer os.Remove(path)
+	defer db.Close()
+
+	assert := assert.New(t)
+
+	fake := &fakeScrobbler{}
+	api, _ := newQueuedScrobbler(fake, db)
+	api.dedupWindow = DefaultDedupWindow
+
+	now := time.Now().UTC()
+	track := Track{Title: "Track", Artist: "John", TrackNumber: -1, Duration: 200, Timestamp: now}
+	again := track
+	again.Timestamp = now.Add(3 * time.Second)
+	repeat := track
+	repeat.Timestamp = now.Add(200 * time.Second)
+
+	_, err := api.Scrobble(track)
+	assert.Nil(err)
+	_, err = api.Scrobble(again)
+	assert.Nil(err)
+	_, err = api.Scrobble(repeat, repeat)
+	assert.Nil(err)
+
+	assert.Equal([]Track{track, repeat}, fake.submitted)
+}
+
+func TestParseAge(t *testing.T) {
+	assert := assert.New(t)
+
+	d, err := ParseAge("14d")
+	assert.Nil(err)
+	assert.Equal(14*24*time.Hour, d)
+
+	d, err = ParseAge("90m")
+	assert.Nil(err)
+	assert.Equal(90*time.Minute, d)
+
+	for _, s := range []string{"", "d", "-1d", "soon"} {
+		_, err := ParseAge(s)
+		assert.NotNil(err, s)
+	}
+}
+
+func TestNextBackoff(t *testing.T) {
+	assert := assert.New(t)
+
+	offline := &lastfm.Err{Code: lastfm.ErrServiceOffline}
+	assert.Equal(minBackoff, nextBackoff(0, offline))
+	assert.Equal(2*minBackoff, nextBackoff(minBackoff, offline))
+	assert.Equal(maxBackoff, nextBackoff(maxBackoff, offline))
+
+	limited := &lastfm.Err{Code: lastfm.ErrRateLimitExceeded}
+	assert.Equal(rateLimitBackoff, nextBackoff(0, limited))
+
+	badKey := &lastfm.Err{Code: lastfm.ErrInvalidApiKey}
+	assert.Equal(maxBackoff, nextBackoff(0, badKey))
+}
+
+// slowScrobbler blocks every request until release is closed.
+type slowScrobbler struct {
+	fakeScrobbler
+	release chan struct{}
+}
+
+func (s *slowScrobbler) Scrobble(tracks ...Track) ([]Result, error) {
+	<-s.release
+	return s.fakeScrobbler.Scrobble(tracks...)
+}
+
+// chanScrobbler passes every submitted track on to got.
+type chanScrobbler struct {
+	fakeScrobbler
+	got chan Track
+}
+
+func (s *chanScrobbler) Scrobble(tracks ...Track) ([]Result, error) {
+	for _, track := range tracks {
+		s.got <- track
+	}
+	return acceptedResults(tracks), nil
+}
+
+func TestDispatcherSlowService(t *testing.T) {
+	assert := assert.New(t)
+
+	slow := &slowScrobbler{fakeScrobbler{name: "slow"}, make(chan struct{})}
+	fast := &chanScrobbler{fakeScrobbler{name: "fast"}, make(chan Track, dispatchBuffer)}
+	d := NewDispatcher([]Scrobbler{slow, fast})
+
+	var tracks []Track
+	for i := 0; i < dispatchBuffer; i++ {
+		track := Track{Title: "Track", Artist: "John", Album: "Cool", AlbumArtist: "John", TrackNumber: int32(i), Timestamp: time.Now().UTC()}
+		tracks = append(tracks, track)
+		d.Scrobble(track)
+	}
+
+	// everything reaches the fast service while the slow one is stuck
+	for _, track := range tracks {
+		select {
+		case got := <-fast.got:
+			assert.Equal(track, got)
+		case <-time.After(5 * time.Second):
+			t.Fatal("fast service stalled")
+		}
+	}
+
+	close(slow.release)
+	d.Close()
+}
+
+func TestNewListen(t *testing.T) {
+	assert := assert.New(t)
+
+	listen := newListen(Track{
+		Title:        "Song",
+		Artist:       "A",
+		Artists:      []string{"A", "B"},
+		AlbumArtist:  "A",
+		TrackNumber:  -1,
+		DiscNumber:   2,
+		Date:         "2001-05-14",
+		Duration:     200,
+		Genres:       []string{"Jazz"},
+		RecordingID:  "rec",
+		ReleaseID:    "rel",
+		ArtistIDs:    []string{"a", "b"},
+		AlbumArtists: []string{"A"},
+	})
+
+	info := listen.TrackMetadata.AdditionalInfo
+	assert.Equal("A", listen.TrackMetadata.ArtistName)
+	assert.Equal(int32(0), info.TrackNumber)
+	assert.Equal(int32(2), info.DiscNumber)
+	assert.Equal(uint32(200000), info.DurationMs)
+	assert.Equal("", info.ReleaseArtist)
+	assert.Equal([]string{"A", "B"}, info.ArtistNames)
+	assert.Equal([]string{"A"}, info.ReleaseArtistNames)
+	assert.Equal("2001-05-14", info.Date)
+	assert.Equal([]string{"Jazz"}, info.Tags)
+	assert.Equal("rec", info.RecordingMbid)
+	assert.Equal("rel", info.ReleaseMbid)
+	assert.Equal([]string{"a", "b"}, info.ArtistMbids)
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+
+	"github.com/softashell/mpd-scrobbler/scrobble"
+)
+
+// Credentials can be given literally, with ${ENV} references, or be read
+// from the output of a command or from a file, e.g.
+//
+//	password_command = "pass show lastfm"
+//	secret_file = "${HOME}/.config/mpd-scrobbler/lastfm.secret"
+//
+// Errors name the field, never its value.
+
+type ServiceConfig struct {
+	scrobble.Config
+	KeyCommand      string `toml:"key_command"`
+	KeyFile         string `toml:"key_file"`
+	SecretCommand   string `toml:"secret_command"`
+	SecretFile      string `toml:"secret_file"`
+	PasswordCommand string `toml:"password_command"`
+	PasswordFile    string `toml:"password_file"`
+	TokenCommand    string `toml:"token_command"`
+	TokenFile       string `toml:"token_file"`
+}
+
+var envRegexp = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// expandEnv replaces ${NAME} with the value of the environment variable.
+// A lone $ is kept, so literal passwords are safe to use.
+func expandEnv(field, s string) (string, error) {
+	var err error
+	s = envRegexp.ReplaceAllStringFunc(s, func(ref string) string {
+		name := envRegexp.FindStringSubmatch(ref)[1]
+		v, ok := os.LookupEnv(name)
+		if !ok && err == nil {
+			err = fmt.Errorf("%s: environment variable %s is not set", field, name)
+		}
+		return v
+	})
+	return s, err
+}
+
+// resolveSecret returns the value of the credential field given by at most
+// one of its literal, _command or _file variants.
+func resolveSecret(field, literal, command, file string) (string, error) {
+	set := 0
+	for _, v := range []string{literal, command, file} {
+		if v != "" {
+			set++
+		}
+	}
+	if set > 1 {
+		return "", fmt.Errorf("only one of %[1]s, %[1]s_command and %[1]s_file may be set", field)
+	}
+
+	switch {
+	case command != "":
+		var stderr bytes.Buffer
+		cmd := exec.Command("sh", "-c", command)
+		cmd.Stderr = &stderr
+		out, err := cmd.Output()
+		if err != nil {
+			return "", fmt.Errorf("%s_command: %v: %s", field, err, strings.TrimSpace(stderr.String()))
+		}
+		return strings.TrimRight(string(out), "\r\n"), nil
+
+	case file != "":
+		path, err := expandEnv(field+"_file", file)
+		if err != nil {
+			return "", err
+		}
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return "", fmt.Errorf("%s_file: %v", field, err)
+		}
+		return strings.TrimRight(string(data), "\r\n"), nil
+	}
+
+	return expandEnv(field, literal)
+}
+
+// resolve returns the service config with all credentials filled in.
+func (s ServiceConfig) resolve() (conf scrobble.Config, err error) {
+	conf = s.Config
+	for _, c := range []struct {
+		field         string
+		value         *string
+		command, file string
+	}{
+		{"key", &conf.Key, s.KeyCommand, s.KeyFile},
+		{"secret", &conf.Secret, s.SecretCommand, s.SecretFile},
+		{"password", &conf.Password, s.PasswordCommand, s.PasswordFile},
+		{"token", &conf.Token, s.TokenCommand, s.TokenFile},
+	} {
+		if *c.value, err = resolveSecret(c.field, *c.value, c.command, c.file); err != nil {
+			return
+		}
+	}
+	return
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResolveSecret(t *testing.T) {
+	assert := assert.New(t)
+
+	os.Setenv("SCROBBLER_TEST_SECRET", "hunter2")
+	defer os.Unsetenv("SCROBBLER_TEST_SECRET")
+
+	file := filepath.Join(os.TempDir(), "secrets_test")
+	ioutil.WriteFile(file, []byte("from file\n"), 0600)
+	defer os.Remove(file)
+
+	for _, c := range []struct {
+		literal, command, file string
+		value                  string
+	}{
+		{"pa$$word", "", "", "pa$$word"},
+		{"${SCROBBLER_TEST_SECRET}!", "", "", "hunter2!"},
+		{"", "echo from command", "", "from command"},
+		{"", "", file, "from file"},
+	} {
+		value, err := resolveSecret("password", c.literal, c.command, c.file)
+		assert.Nil(err)
+		assert.Equal(c.value, value)
+	}
+
+	_, err := resolveSecret("password", "${SCROBBLER_TEST_UNSET}", "", "")
+	assert.EqualError(err, "password: environment variable SCROBBLER_TEST_UNSET is not set")
+
+	_, err = resolveSecret("password", "", "echo hunter2; exit 1", "")
+	assert.EqualError(err, "password_command: exit status 1: ")
+
+	_, err = resolveSecret("secret", "x", "echo y", "")
+	assert.EqualError(err, "only one of secret, secret_command and secret_file may be set")
+}
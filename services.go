@@ -0,0 +1,81 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"sort"
+
+	"github.com/softashell/mpd-scrobbler/scrobble"
+)
+
+// services keeps the running scrobblers in sync with the configured ones.
+type services struct {
+	db         scrobble.Database
+	dispatcher *scrobble.Dispatcher
+	running    map[string]service
+}
+
+type service struct {
+	conf scrobble.Config
+	api  scrobble.Scrobbler
+}
+
+func newServices(db scrobble.Database) *services {
+	return &services{
+		db:         db,
+		dispatcher: scrobble.NewDispatcher(nil),
+		running:    map[string]service{},
+	}
+}
+
+// apply stops services that were removed or changed and starts the new
+// ones. Services that fail to start are skipped and reported.
+func (s *services) apply(confs map[string]scrobble.Config) error {
+	for name, svc := range s.running {
+		if conf, ok := confs[name]; ok && conf == svc.conf {
+			continue
+		}
+		s.stop(name)
+		log.Printf("[%s] Stopped\n", name)
+	}
+
+	var failed []string
+	names := make([]string, 0, len(confs))
+	for name := range confs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if _, ok := s.running[name]; ok {
+			continue
+		}
+
+		api, err := scrobble.New(s.db, name, confs[name])
+		if err != nil {
+			log.Printf("[%s] %v\n", name, err)
+			failed = append(failed, name)
+			continue
+		}
+		s.dispatcher.Add(api)
+		s.running[name] = service{confs[name], api}
+	}
+
+	if len(failed) > 0 {
+		return fmt.Errorf("failed to start %v", failed)
+	}
+	return nil
+}
+
+func (s *services) stop(name string) {
+	s.dispatcher.Remove(name)
+	s.running[name].api.Close()
+	delete(s.running, name)
+}
+
+func (s *services) close() {
+	s.dispatcher.Close()
+	for name := range s.running {
+		s.stop(name)
+	}
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"log"
+	"sync"
+
+	"github.com/softashell/mpd-scrobbler/client"
+	"github.com/softashell/mpd-scrobbler/scrobble"
+)
+
+// watchers runs a client for every watched mpd server and partition, all
+// sending their songs to the same channels.
+type watchers struct {
+	db         scrobble.Database // keeps the state of the clients
+	clients    map[string]*client.Client
+	toSubmit   chan client.Song
+	nowPlaying chan client.Song
+	loves      chan client.Love
+}
+
+func newWatchers(db scrobble.Database) *watchers {
+	return &watchers{
+		db:         db,
+		clients:    map[string]*client.Client{},
+		toSubmit:   make(chan client.Song),
+		nowPlaying: make(chan client.Song),
+		loves:      make(chan client.Love),
+	}
+}
+
+// start watches the given partition of m with a client called name.
+func (w *watchers) start(name string, m MPDConfig, partition string, streams []client.StreamRule) {
+	network, addr, password := m.dialArgs()
+	c := client.New(name, network, addr, password, partition)
+
+	c.Configure(m.SubmitTime, m.SubmitPercentage, m.SubmitMinDuration, m.TitleHack)
+	c.ConfigureStreams(streams)
+	c.ConfigureSticker(m.Sticker)
+	c.Subscribe(m.Channel)
+	c.KeepState(w.db)
+	w.clients[name] = c
+
+	go c.Watch(sleepTime, w.toSubmit, w.nowPlaying, w.loves)
+}
+
+// addPartitions lists the partitions of m, which watches all of them, and
+// starts watching the ones that were created since.
+func (w *watchers) addPartitions(m MPDConfig, streams []client.StreamRule) {
+	names, err := client.Partitions(m.dialArgs())
+	if err != nil {
+		log.Printf("[%s] listing partitions failed: %v\n", m.Name, err)
+		return
+	}
+
+	for _, partition := range names {
+		name := m.Name + "/" + partition
+		if _, ok := w.clients[name]; !ok {
+			log.Printf("[%s] new partition, watching it\n", name)
+			w.start(name, m, partition, streams)
+		}
+	}
+}
+
+// close stops every client, letting them save their state at the same time.
+func (w *watchers) close() {
+	var wg sync.WaitGroup
+	for _, c := range w.clients {
+		wg.Add(1)
+		go func(c *client.Client) {
+			defer wg.Done()
+			c.Close()
+		}(c)
+	}
+	wg.Wait()
+}